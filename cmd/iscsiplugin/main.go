@@ -18,15 +18,47 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	_ "github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend/lio"
+	_ "github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend/pool"
+	_ "github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend/rest"
 	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsi"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
 	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 )
 
 var (
 	endpoint = flag.String("endpoint", "unix:///csi/csi.sock", "CSI endpoint")
 	nodeID   = flag.String("nodeid", "", "node id")
+
+	pathHealInterval = flag.Duration("path-heal-interval", 0, "interval at which missing paths of multipath volumes are logged in again, 0 disables the path healer")
+	metricsAddress   = flag.String("metrics-address", "", "address to expose the driver metrics on, e.g. :29754, empty disables metrics")
+
+	forceDetach            = flag.Bool("force-detach", false, "always force the detach of volumes, without flushing buffered writes, which may lose data")
+	forceDetachGracePeriod = flag.Duration("force-detach-grace-period", 0, "force the detach of volumes that could not be detached within this period, 0 disables forced detach")
+
+	backendName   = flag.String("backend", "", "backend to provision volumes on, empty disables the controller service")
+	backendConfig = flag.String("backend-config", "", "path of the YAML configuration file of the backend")
+
+	cloneCheckpointDir = flag.String("clone-checkpoint-dir", "/var/lib/iscsi.csi.k8s.io/clones", "directory holding the progress of the volumes cloned through the host of the controller, empty disables the host copy")
+
+	topology           = flag.String("topology", "", "topology segments of the node, as comma separated key=value pairs, the keys without prefix are prefixed with topology.iscsi.csi.k8s.io/")
+	topologyNodeLabels = flag.String("topology-node-labels", "", "comma separated labels of the node object added to the topology segments of the node")
+	portalGroupsFile   = flag.String("portal-groups-file", "", "path of the YAML file mapping the portal groups to their portals, the reachability of each group is added to the topology segments of the node")
+	portalProbeTimeout = flag.Duration("portal-probe-timeout", 2*time.Second, "timeout of the connections probing the reachability of the portal groups")
+
+	wipePolicy        = flag.String("wipe-policy", "none", "how the controller wipes the LUNs of the deleted volumes before deleting them by default: discard, zero or none, the wipePolicy parameter of the StorageClass overrides it")
+	wipeCheckpointDir = flag.String("wipe-checkpoint-dir", "/var/lib/iscsi.csi.k8s.io/wipes", "directory holding the progress of the wipes of the deleted volumes")
+
+	fsckTimeout = flag.Duration("fsck-timeout", 10*time.Minute, "timeout of the check or repair of the filesystems of the volumes with a fsckPolicy")
+
+	initiatorNameFile = flag.String("initiator-name-file", "/etc/iscsi/initiatorname.iscsi", "open-iscsi file holding the initiator name of the node, recorded in its node object, empty to not record it")
 )
 
 func init() {
@@ -40,6 +72,87 @@ func main() {
 }
 
 func handle() {
-	d := iscsi.NewDriver(*nodeID, *endpoint)
+	var b backend.Backend
+	if *backendName != "" {
+		var config []byte
+		if *backendConfig != "" {
+			var err error
+			config, err = os.ReadFile(*backendConfig)
+			if err != nil {
+				klog.Fatalf("failed to read backend config: %v", err)
+			}
+		}
+		var err error
+		b, err = backend.New(*backendName, config)
+		if err != nil {
+			klog.Fatalf("%v", err)
+		}
+	}
+
+	segments, err := parseTopology(*topology)
+	if err != nil {
+		klog.Fatalf("invalid topology: %v", err)
+	}
+	var portalGroups map[string][]string
+	if *portalGroupsFile != "" {
+		data, err := os.ReadFile(*portalGroupsFile)
+		if err != nil {
+			klog.Fatalf("failed to read portal groups: %v", err)
+		}
+		if err := yaml.UnmarshalStrict(data, &portalGroups); err != nil {
+			klog.Fatalf("failed to parse portal groups: %v", err)
+		}
+	}
+	var nodeLabels []string
+	if *topologyNodeLabels != "" {
+		nodeLabels = strings.Split(*topologyNodeLabels, ",")
+	}
+	wipeMode, err := lunio.ParseWipePolicy(*wipePolicy)
+	if err != nil {
+		klog.Fatalf("%v", err)
+	}
+	if wipeMode != "" && b != nil && !b.Capabilities().Get {
+		klog.Fatalf("backend %s cannot look up the LUNs to wipe, the wipe policy must be none", *backendName)
+	}
+	if wipeMode != "" && b != nil && b.Capabilities().Wipe {
+		klog.Fatalf("backend %s already wipes the LUNs it deletes, the wipe policy must be none", *backendName)
+	}
+
+	driverOptions := iscsi.DriverOptions{
+		NodeID:           *nodeID,
+		Endpoint:         *endpoint,
+		PathHealInterval: *pathHealInterval,
+		MetricsAddress:   *metricsAddress,
+
+		ForceDetach:            *forceDetach,
+		ForceDetachGracePeriod: *forceDetachGracePeriod,
+		Backend:                b,
+		InitiatorNameFile:      *initiatorNameFile,
+		CloneCheckpointDir:     *cloneCheckpointDir,
+		Topology:               segments,
+		TopologyNodeLabels:     nodeLabels,
+		PortalGroups:           portalGroups,
+		PortalProbeTimeout:     *portalProbeTimeout,
+		FsckTimeout:            *fsckTimeout,
+		WipePolicy:             wipeMode,
+		WipeCheckpointDir:      *wipeCheckpointDir,
+	}
+	d := iscsi.NewDriver(&driverOptions)
 	d.Run()
 }
+
+// parseTopology parses comma separated key=value pairs
+func parseTopology(s string) (map[string]string, error) {
+	segments := map[string]string{}
+	if s == "" {
+		return segments, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		segments[key] = value
+	}
+	return segments, nil
+}
@@ -33,7 +33,20 @@ if [ $ver != "master" ]; then
   repo="$repo/$ver"
 fi
 
+controller=false
+if [[ "$#" -gt 1 ]] && [[ "$2" == *"controller"* ]]; then
+  echo "install controller, which requires the attach of the volumes"
+  controller=true
+fi
+
 echo "Installing iscsi.csi.k8s.io CSI driver, version: $ver ..."
-kubectl apply -f $repo/csi-iscsi-driverinfo.yaml
+kubectl apply -f $repo/rbac-csi-iscsi-node.yaml
+if [ $controller == "true" ]; then
+  kubectl apply -f $repo/controller/csi-iscsi-driverinfo.yaml
+  kubectl apply -f $repo/controller/rbac-csi-iscsi-controller.yaml
+  kubectl apply -f $repo/controller/csi-iscsi-controller.yaml
+else
+  kubectl apply -f $repo/csi-iscsi-driverinfo.yaml
+fi
 kubectl apply -f $repo/csi-iscsi-node.yaml
 echo 'iscsi.csi.k8s.io CSI driver installed successfully.'
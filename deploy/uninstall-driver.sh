@@ -34,6 +34,11 @@ if [ $ver != "master" ]; then
 fi
 
 echo "Uninstalling iscsi.csi.k8s.io CSI driver, version: $ver ..."
+if [[ "$#" -gt 1 ]] && [[ "$2" == *"controller"* ]]; then
+  kubectl delete -f $repo/controller/csi-iscsi-controller.yaml
+  kubectl delete -f $repo/controller/rbac-csi-iscsi-controller.yaml
+fi
 kubectl delete -f $repo/csi-iscsi-driverinfo.yaml
 kubectl delete -f $repo/csi-iscsi-node.yaml
+kubectl delete -f $repo/rbac-csi-iscsi-node.yaml
 echo 'iscsi.csi.k8s.io CSI driver uninstalled successfully.'
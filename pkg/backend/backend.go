@@ -0,0 +1,276 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backend defines the interface through which the controller service provisions the LUNs
+// of the volumes of the driver on a storage system.
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a LUN or a snapshot does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when a LUN or a snapshot with the same name but incompatible
+	// attributes already exists
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrNotSupported is returned by the operations that the backend does not support
+	ErrNotSupported = errors.New("not supported")
+	// ErrOutOfCapacity is returned when the backend has no room left for a LUN
+	ErrOutOfCapacity = errors.New("out of capacity")
+	// ErrNotAccessible is returned when the backend cannot create a LUN accessible from the
+	// requisite topology
+	ErrNotAccessible = errors.New("not accessible from the requisite topology")
+	// ErrInProgress is returned while an operation that outlasts the calls, e.g. the wipe of a deleted
+	// LUN, runs in the background, the call is retried until it is complete
+	ErrInProgress = errors.New("in progress")
+)
+
+// Backend provisions LUNs on a storage system. The operations must be idempotent, as the
+// controller service retries them until they succeed.
+type Backend interface {
+	// Capabilities returns the optional operations supported by the backend
+	Capabilities() Capabilities
+
+	// CreateLUN creates a LUN, or returns the existing one if a LUN with the same name and a
+	// compatible capacity already exists. A LUN restored from a snapshot is at least as large as
+	// the snapshot.
+	CreateLUN(ctx context.Context, req *CreateLUNRequest) (*LUN, error)
+	// DeleteLUN deletes a LUN, it returns ErrNotFound if the LUN does not exist
+	DeleteLUN(ctx context.Context, lunID string) error
+	// GetLUN returns a LUN, or ErrNotFound if the LUN does not exist
+	GetLUN(ctx context.Context, lunID string) (*LUN, error)
+	// ListLUNs returns the LUNs provisioned by the backend
+	ListLUNs(ctx context.Context) ([]*LUN, error)
+	// ExpandLUN grows a LUN to at least the given capacity and returns its new capacity
+	ExpandLUN(ctx context.Context, lunID string, capacityBytes int64) (int64, error)
+
+	// GrantInitiator gives an initiator access to a LUN and returns how to reach it
+	GrantInitiator(ctx context.Context, lunID string, host Host) (*Access, error)
+	// RevokeInitiator removes the access of an initiator to a LUN
+	RevokeInitiator(ctx context.Context, lunID string, host Host) error
+
+	// CreateSnapshot takes a snapshot of a LUN, or returns the existing one if a snapshot with
+	// the same name of the same LUN already exists
+	CreateSnapshot(ctx context.Context, lunID, name string) (*Snapshot, error)
+	// DeleteSnapshot deletes a snapshot of a LUN, it returns ErrNotFound if the snapshot does not
+	// exist
+	DeleteSnapshot(ctx context.Context, lunID, snapshotID string) error
+	// ListSnapshots returns the snapshots of a LUN, or of all the LUNs if lunID is empty
+	ListSnapshots(ctx context.Context, lunID string) ([]*Snapshot, error)
+
+	// Capacity returns the capacity available for LUNs created with the given parameters and
+	// accessible from the nodes in segment, or from any node if segment is nil
+	Capacity(ctx context.Context, parameters map[string]string, segment Topology) (int64, error)
+}
+
+// Capabilities are the optional operations supported by a backend
+type Capabilities struct {
+	Expand   bool
+	Snapshot bool
+	// ListSnapshots is set if the snapshots can be listed, it requires Snapshot
+	ListSnapshots bool
+	// Clone is set if the backend creates LUNs from other LUNs, the LUNs of the other backends are
+	// cloned by copying their content through the host of the controller
+	Clone    bool
+	Capacity bool
+	ACL      bool
+	// Get is set if the LUNs can be looked up
+	Get bool
+	// List is set if the LUNs can be listed, it requires Get
+	List bool
+	// Hosts is set if the LUNs report the hosts granted access to them, it requires ACL
+	Hosts bool
+	// Wipe is set if the backend wipes the LUNs itself when they are deleted
+	Wipe bool
+}
+
+// CreateLUNRequest describes a LUN to create
+type CreateLUNRequest struct {
+	// Name is unique per volume, it is generated by the provisioner
+	Name string
+	// RequiredBytes is the minimal capacity of the LUN
+	RequiredBytes int64
+	// LimitBytes is the maximal capacity of the LUN, 0 if unlimited
+	LimitBytes int64
+	// Parameters are the StorageClass parameters
+	Parameters map[string]string
+	// SourceSnapshot is the snapshot the LUN is restored from, nil for an empty LUN
+	SourceSnapshot *SnapshotRef
+	// SourceLUNID is the LUN the LUN is cloned from, empty for an empty LUN. It is only set if the
+	// backend supports Clone.
+	SourceLUNID string
+	// Requisite are the segments the LUN must be accessible from one of, empty for any segment
+	Requisite []Topology
+	// Preferred are the segments the LUN should be accessible from, the first ones first
+	Preferred []Topology
+}
+
+// SnapshotRef refers to a snapshot of a LUN
+type SnapshotRef struct {
+	LUNID      string
+	SnapshotID string
+}
+
+// LUN is a LUN provisioned by a backend
+type LUN struct {
+	ID            string
+	Name          string
+	CapacityBytes int64
+	Target        Target
+	// Context is added to the volume context of the volume of the LUN
+	Context map[string]string
+	// Hosts are the hosts granted access to the LUN, with the Hosts capability
+	Hosts []Host
+	// Health are the adverse conditions of the LUN known to the backend, empty if it is healthy
+	Health []HealthCondition
+	// Topology are the segments the LUN is accessible from, nil if it is accessible from every node
+	Topology []Topology
+}
+
+// HealthStatus is the kind of an adverse condition of a LUN
+type HealthStatus string
+
+const (
+	// HealthDegraded is a LUN that works with reduced performance or redundancy
+	HealthDegraded HealthStatus = "Degraded"
+	// HealthInaccessible is a LUN that cannot be read or written
+	HealthInaccessible HealthStatus = "Inaccessible"
+	// HealthDataLoss is a LUN that lost some or all of its data
+	HealthDataLoss HealthStatus = "DataLoss"
+)
+
+// HealthCondition is an adverse condition of a LUN
+type HealthCondition struct {
+	Status HealthStatus
+	// Reason is a short CamelCase description of the condition
+	Reason  string
+	Message string
+}
+
+// Target is how initiators reach a LUN
+type Target struct {
+	// Portals are the portals of the target, the first one being the primary portal
+	Portals []string
+	IQN     string
+	LUN     int32
+}
+
+// Context returns the volume context that the node service parses to attach the LUN
+func (t Target) Context() (map[string]string, error) {
+	if len(t.Portals) == 0 || t.IQN == "" {
+		return nil, fmt.Errorf("target has no portal or IQN")
+	}
+
+	volumeContext := map[string]string{
+		"targetPortal": t.Portals[0],
+		"iqn":          t.IQN,
+		"lun":          strconv.Itoa(int(t.LUN)),
+	}
+	if len(t.Portals) > 1 {
+		portals, err := json.Marshal(t.Portals[1:])
+		if err != nil {
+			return nil, err
+		}
+		volumeContext["portals"] = string(portals)
+	}
+	return volumeContext, nil
+}
+
+// Host is a node that accesses LUNs
+type Host struct {
+	NodeID       string
+	InitiatorIQN string
+}
+
+// Access is how a host reaches a LUN it was granted access to
+type Access struct {
+	Target Target
+	// CHAP is the session CHAP credentials of the host, nil if CHAP is not used
+	CHAP *CHAP
+}
+
+// CHAP are CHAP credentials
+type CHAP struct {
+	User     string
+	Password string
+}
+
+// Snapshot is a point in time copy of a LUN
+type Snapshot struct {
+	ID           string
+	Name         string
+	SourceLUNID  string
+	SizeBytes    int64
+	CreationTime time.Time
+	ReadyToUse   bool
+}
+
+// Factory creates a backend from its configuration
+type Factory func(config []byte) (Backend, error)
+
+var (
+	factoriesLock sync.Mutex
+	factories     = map[string]Factory{}
+)
+
+// Register makes a backend available by name, it is called from the init function of the backends
+func Register(name string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("backend %s registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// New creates the backend registered with the given name
+func New(name string, config []byte) (Backend, error) {
+	factoriesLock.Lock()
+	factory, ok := factories[name]
+	factoriesLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q, available backends: %v", name, Names())
+	}
+
+	b, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backend %s: %v", name, err)
+	}
+	return b, nil
+}
+
+// Names returns the names of the registered backends
+func Names() []string {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	names := []string{}
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,357 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake implements an in-memory backend for tests. It is not linked in the driver, the tests
+// that create backends by name register it with Register.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"sigs.k8s.io/yaml"
+)
+
+// Name is the name the backend is registered with
+const Name = "fake"
+
+const (
+	defaultPortal    = "127.0.0.1:3260"
+	defaultIQNPrefix = "iqn.2026-01.io.k8s.csi.iscsi.fake"
+)
+
+// Register registers the backend with its name, it must be called once
+func Register() {
+	backend.Register(Name, func(config []byte) (backend.Backend, error) {
+		cfg := Config{}
+		if err := yaml.UnmarshalStrict(config, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+		return New(cfg), nil
+	})
+}
+
+// Config is the configuration of the fake backend
+type Config struct {
+	// CapacityBytes is the total capacity of the backend, 0 for unlimited
+	CapacityBytes int64 `json:"capacityBytes"`
+	// Portals are the portals of the targets of the LUNs
+	Portals []string `json:"portals"`
+	// IQNPrefix is the prefix of the IQN of the targets, which is suffixed with the LUN ID
+	IQNPrefix string `json:"iqnPrefix"`
+	// Topology are the segments the LUNs are accessible from, empty for every node
+	Topology []backend.Topology `json:"topology"`
+}
+
+// Backend keeps its LUNs, ACLs and snapshots in memory
+type Backend struct {
+	config Config
+
+	lock      sync.Mutex
+	luns      map[string]*backend.LUN
+	acls      map[string]map[string]backend.Host
+	snapshots map[string]*backend.Snapshot
+}
+
+var _ backend.Backend = &Backend{}
+
+// New creates a fake backend
+func New(config Config) *Backend {
+	if len(config.Portals) == 0 {
+		config.Portals = []string{defaultPortal}
+	}
+	if config.IQNPrefix == "" {
+		config.IQNPrefix = defaultIQNPrefix
+	}
+	return &Backend{
+		config:    config,
+		luns:      map[string]*backend.LUN{},
+		acls:      map[string]map[string]backend.Host{},
+		snapshots: map[string]*backend.Snapshot{},
+	}
+}
+
+func (b *Backend) Capabilities() backend.Capabilities {
+	return backend.Capabilities{
+		Expand:        true,
+		Snapshot:      true,
+		ListSnapshots: true,
+		Clone:         true,
+		Get:           true,
+		List:          true,
+		Hosts:         true,
+		Capacity:      b.config.CapacityBytes > 0,
+		ACL:           true,
+	}
+}
+
+func (b *Backend) CreateLUN(ctx context.Context, req *backend.CreateLUNRequest) (*backend.LUN, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if lun, ok := b.luns[req.Name]; ok {
+		if lun.CapacityBytes < req.RequiredBytes || (req.LimitBytes > 0 && lun.CapacityBytes > req.LimitBytes) {
+			return nil, fmt.Errorf("LUN %s of %d bytes: %w", req.Name, lun.CapacityBytes, backend.ErrAlreadyExists)
+		}
+		return copyLUN(lun), nil
+	}
+
+	if !backend.Accessible(b.topology(), req.Requisite) {
+		return nil, fmt.Errorf("LUN %s: %w", req.Name, backend.ErrNotAccessible)
+	}
+	capacity := req.RequiredBytes
+	if ref := req.SourceSnapshot; ref != nil {
+		snapshot, ok := b.snapshots[ref.SnapshotID]
+		if !ok || snapshot.SourceLUNID != ref.LUNID {
+			return nil, fmt.Errorf("snapshot %s of LUN %s: %w", ref.SnapshotID, ref.LUNID, backend.ErrNotFound)
+		}
+		capacity = max(capacity, snapshot.SizeBytes)
+		if req.LimitBytes > 0 && capacity > req.LimitBytes {
+			return nil, fmt.Errorf("snapshot %s of %d bytes exceeds the limit of %d bytes", ref.SnapshotID, snapshot.SizeBytes, req.LimitBytes)
+		}
+	} else if req.SourceLUNID != "" {
+		source, ok := b.luns[req.SourceLUNID]
+		if !ok {
+			return nil, fmt.Errorf("LUN %s: %w", req.SourceLUNID, backend.ErrNotFound)
+		}
+		capacity = max(capacity, source.CapacityBytes)
+		if req.LimitBytes > 0 && capacity > req.LimitBytes {
+			return nil, fmt.Errorf("LUN %s of %d bytes exceeds the limit of %d bytes", req.SourceLUNID, source.CapacityBytes, req.LimitBytes)
+		}
+	}
+	if b.config.CapacityBytes > 0 && b.usedBytes()+capacity > b.config.CapacityBytes {
+		return nil, fmt.Errorf("LUN %s of %d bytes: %w", req.Name, capacity, backend.ErrOutOfCapacity)
+	}
+
+	lun := &backend.LUN{
+		ID:            req.Name,
+		Name:          req.Name,
+		CapacityBytes: capacity,
+		Target: backend.Target{
+			Portals: append([]string{}, b.config.Portals...),
+			IQN:     fmt.Sprintf("%s:%s", b.config.IQNPrefix, req.Name),
+			LUN:     0,
+		},
+		Topology: b.topology(),
+	}
+	b.luns[lun.ID] = lun
+	return copyLUN(lun), nil
+}
+
+func (b *Backend) DeleteLUN(ctx context.Context, lunID string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.luns[lunID]; !ok {
+		return fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	delete(b.luns, lunID)
+	delete(b.acls, lunID)
+	return nil
+}
+
+func (b *Backend) GetLUN(ctx context.Context, lunID string) (*backend.LUN, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	lun, ok := b.luns[lunID]
+	if !ok {
+		return nil, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	return b.describeLUN(lun), nil
+}
+
+func (b *Backend) ListLUNs(ctx context.Context) ([]*backend.LUN, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	luns := make([]*backend.LUN, 0, len(b.luns))
+	for _, lun := range b.luns {
+		luns = append(luns, b.describeLUN(lun))
+	}
+	return luns, nil
+}
+
+// SetHealth sets the adverse conditions of a LUN, none if it is healthy
+func (b *Backend) SetHealth(lunID string, conditions ...backend.HealthCondition) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	lun, ok := b.luns[lunID]
+	if !ok {
+		return fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	lun.Health = conditions
+	return nil
+}
+
+// describeLUN returns a copy of a LUN with the hosts granted access to it
+func (b *Backend) describeLUN(lun *backend.LUN) *backend.LUN {
+	c := copyLUN(lun)
+	for _, host := range b.acls[lun.ID] {
+		c.Hosts = append(c.Hosts, host)
+	}
+	return c
+}
+
+func (b *Backend) ExpandLUN(ctx context.Context, lunID string, capacityBytes int64) (int64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	lun, ok := b.luns[lunID]
+	if !ok {
+		return 0, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	if capacityBytes <= lun.CapacityBytes {
+		return lun.CapacityBytes, nil
+	}
+	if b.config.CapacityBytes > 0 && b.usedBytes()+capacityBytes-lun.CapacityBytes > b.config.CapacityBytes {
+		return 0, fmt.Errorf("LUN %s of %d bytes: %w", lunID, capacityBytes, backend.ErrOutOfCapacity)
+	}
+	lun.CapacityBytes = capacityBytes
+	return lun.CapacityBytes, nil
+}
+
+func (b *Backend) GrantInitiator(ctx context.Context, lunID string, host backend.Host) (*backend.Access, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	lun, ok := b.luns[lunID]
+	if !ok {
+		return nil, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	if b.acls[lunID] == nil {
+		b.acls[lunID] = map[string]backend.Host{}
+	}
+	b.acls[lunID][host.InitiatorIQN] = host
+	return &backend.Access{Target: copyLUN(lun).Target}, nil
+}
+
+func (b *Backend) RevokeInitiator(ctx context.Context, lunID string, host backend.Host) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.luns[lunID]; !ok {
+		return fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	delete(b.acls[lunID], host.InitiatorIQN)
+	return nil
+}
+
+// Initiators returns the hosts that were granted access to a LUN
+func (b *Backend) Initiators(lunID string) []backend.Host {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	hosts := []backend.Host{}
+	for _, host := range b.acls[lunID] {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+func (b *Backend) CreateSnapshot(ctx context.Context, lunID, name string) (*backend.Snapshot, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	lun, ok := b.luns[lunID]
+	if !ok {
+		return nil, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	if snapshot, ok := b.snapshots[name]; ok {
+		if snapshot.SourceLUNID != lunID {
+			return nil, fmt.Errorf("snapshot %s of LUN %s: %w", name, snapshot.SourceLUNID, backend.ErrAlreadyExists)
+		}
+		s := *snapshot
+		return &s, nil
+	}
+
+	snapshot := &backend.Snapshot{
+		ID:           name,
+		Name:         name,
+		SourceLUNID:  lunID,
+		SizeBytes:    lun.CapacityBytes,
+		CreationTime: time.Now(),
+		ReadyToUse:   true,
+	}
+	b.snapshots[name] = snapshot
+	s := *snapshot
+	return &s, nil
+}
+
+func (b *Backend) DeleteSnapshot(ctx context.Context, lunID, snapshotID string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if snapshot, ok := b.snapshots[snapshotID]; !ok || snapshot.SourceLUNID != lunID {
+		return fmt.Errorf("snapshot %s of LUN %s: %w", snapshotID, lunID, backend.ErrNotFound)
+	}
+	delete(b.snapshots, snapshotID)
+	return nil
+}
+
+func (b *Backend) ListSnapshots(ctx context.Context, lunID string) ([]*backend.Snapshot, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	snapshots := []*backend.Snapshot{}
+	for _, snapshot := range b.snapshots {
+		if lunID == "" || snapshot.SourceLUNID == lunID {
+			s := *snapshot
+			snapshots = append(snapshots, &s)
+		}
+	}
+	return snapshots, nil
+}
+
+func (b *Backend) Capacity(ctx context.Context, parameters map[string]string, segment backend.Topology) (int64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.config.CapacityBytes == 0 {
+		return 0, fmt.Errorf("unlimited capacity: %w", backend.ErrNotSupported)
+	}
+	if segment != nil && !backend.AccessibleFrom(b.topology(), segment) {
+		return 0, nil
+	}
+	return b.config.CapacityBytes - b.usedBytes(), nil
+}
+
+// topology returns the segments the LUNs are accessible from, nil for every node
+func (b *Backend) topology() []backend.Topology {
+	if len(b.config.Topology) == 0 {
+		return nil
+	}
+	return b.config.Topology
+}
+
+func (b *Backend) usedBytes() int64 {
+	var used int64
+	for _, lun := range b.luns {
+		used += lun.CapacityBytes
+	}
+	return used
+}
+
+func copyLUN(lun *backend.LUN) *backend.LUN {
+	l := *lun
+	l.Target.Portals = append([]string{}, lun.Target.Portals...)
+	l.Health = append([]backend.HealthCondition{}, lun.Health...)
+	return &l
+}
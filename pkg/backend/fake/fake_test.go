@@ -0,0 +1,229 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+)
+
+func TestCreateLUN(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		req     backend.CreateLUNRequest
+		wantErr error
+		wantCap int64
+	}{
+		{
+			name:    "new LUN",
+			req:     backend.CreateLUNRequest{Name: "new", RequiredBytes: 1 << 30},
+			wantCap: 1 << 30,
+		},
+		{
+			name:    "existing LUN that fits",
+			req:     backend.CreateLUNRequest{Name: "existing", RequiredBytes: 1 << 20, LimitBytes: 1 << 30},
+			wantCap: 1 << 30,
+		},
+		{
+			name:    "existing LUN too small",
+			req:     backend.CreateLUNRequest{Name: "existing", RequiredBytes: 2 << 30},
+			wantErr: backend.ErrAlreadyExists,
+		},
+		{
+			name:    "existing LUN too large",
+			req:     backend.CreateLUNRequest{Name: "existing", LimitBytes: 1 << 20},
+			wantErr: backend.ErrAlreadyExists,
+		},
+		{
+			name:    "out of capacity",
+			config:  Config{CapacityBytes: 3 << 29},
+			req:     backend.CreateLUNRequest{Name: "new", RequiredBytes: 1 << 30},
+			wantErr: backend.ErrOutOfCapacity,
+		},
+		{
+			name:    "missing source LUN",
+			req:     backend.CreateLUNRequest{Name: "new", SourceLUNID: "missing"},
+			wantErr: backend.ErrNotFound,
+		},
+		{
+			name:    "missing source snapshot",
+			req:     backend.CreateLUNRequest{Name: "new", SourceSnapshot: &backend.SnapshotRef{LUNID: "existing", SnapshotID: "missing"}},
+			wantErr: backend.ErrNotFound,
+		},
+		{
+			name:    "not accessible",
+			config:  Config{Topology: []backend.Topology{{"zone": "a"}}},
+			req:     backend.CreateLUNRequest{Name: "new", Requisite: []backend.Topology{{"zone": "b"}}},
+			wantErr: backend.ErrNotAccessible,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			b := New(test.config)
+			if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "existing", RequiredBytes: 1 << 30}); err != nil {
+				t.Fatalf("failed to create existing LUN: %v", err)
+			}
+
+			lun, err := b.CreateLUN(ctx, &test.req)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if err == nil && lun.CapacityBytes != test.wantCap {
+				t.Errorf("expected capacity %d, got %d", test.wantCap, lun.CapacityBytes)
+			}
+		})
+	}
+}
+
+func TestMissingLUN(t *testing.T) {
+	ctx := context.Background()
+	host := backend.Host{NodeID: "node", InitiatorIQN: "iqn.2026-01.io.example:node"}
+	tests := []struct {
+		name string
+		call func(b *Backend) error
+	}{
+		{
+			name: "delete",
+			call: func(b *Backend) error { return b.DeleteLUN(ctx, "missing") },
+		},
+		{
+			name: "get",
+			call: func(b *Backend) error { _, err := b.GetLUN(ctx, "missing"); return err },
+		},
+		{
+			name: "expand",
+			call: func(b *Backend) error { _, err := b.ExpandLUN(ctx, "missing", 1<<30); return err },
+		},
+		{
+			name: "grant",
+			call: func(b *Backend) error { _, err := b.GrantInitiator(ctx, "missing", host); return err },
+		},
+		{
+			name: "revoke",
+			call: func(b *Backend) error { return b.RevokeInitiator(ctx, "missing", host) },
+		},
+		{
+			name: "snapshot",
+			call: func(b *Backend) error { _, err := b.CreateSnapshot(ctx, "missing", "snap"); return err },
+		},
+		{
+			name: "delete snapshot",
+			call: func(b *Backend) error { return b.DeleteSnapshot(ctx, "missing", "snap") },
+		},
+		{
+			name: "delete deleted LUN",
+			call: func(b *Backend) error {
+				if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "deleted"}); err != nil {
+					return err
+				}
+				if err := b.DeleteLUN(ctx, "deleted"); err != nil {
+					return err
+				}
+				return b.DeleteLUN(ctx, "deleted")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.call(New(Config{})); !errors.Is(err, backend.ErrNotFound) {
+				t.Errorf("expected %v, got %v", backend.ErrNotFound, err)
+			}
+		})
+	}
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		lunID   string
+		wantErr error
+	}{
+		{
+			name:  "retry on the same LUN",
+			lunID: "lun1",
+		},
+		{
+			name:    "name taken by a snapshot of another LUN",
+			lunID:   "lun2",
+			wantErr: backend.ErrAlreadyExists,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			b := New(Config{})
+			for _, name := range []string{"lun1", "lun2"} {
+				if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: name, RequiredBytes: 1 << 20}); err != nil {
+					t.Fatalf("failed to create LUN %s: %v", name, err)
+				}
+			}
+			first, err := b.CreateSnapshot(ctx, "lun1", "snap")
+			if err != nil {
+				t.Fatalf("failed to create snapshot: %v", err)
+			}
+
+			snapshot, err := b.CreateSnapshot(ctx, test.lunID, "snap")
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if err == nil && *snapshot != *first {
+				t.Errorf("expected snapshot %+v, got %+v", first, snapshot)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register()
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name:   "empty config",
+			config: "",
+		},
+		{
+			name:   "valid config",
+			config: "capacityBytes: 1073741824\nportals: [\"10.0.0.1:3260\"]\n",
+		},
+		{
+			name:    "unknown field",
+			config:  "capacity: 1Gi\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := backend.New(Name, []byte(test.config))
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error %t, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
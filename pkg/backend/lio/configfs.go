@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readAttr returns the value of a configfs attribute, or an empty string if it cannot be read
+func readAttr(path string) string {
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// writeAttr sets the value of a configfs attribute. Its group exists on configfs, it is only created
+// when the root is a plain directory, as used by tests.
+func writeAttr(path, value string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		return fmt.Errorf("failed to write %q to %s: %v", value, path, err)
+	}
+	return nil
+}
+
+// ensureAttr sets the value of a configfs attribute unless it already has it, as LIO refuses to
+// enable again what is already enabled
+func ensureAttr(path, value string) error {
+	if readAttr(path) == value {
+		return nil
+	}
+	return writeAttr(path, value)
+}
+
+// ensureSymlink links two configfs groups, unless they are already linked
+func ensureSymlink(target, link string) error {
+	if _, err := os.Lstat(link); err == nil {
+		return nil
+	}
+	if err := os.Symlink(target, link); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %v", link, target, err)
+	}
+	return nil
+}
+
+// removeTree removes a configfs group and the groups and links it contains. On configfs, the
+// attributes and the default groups go away with their group and cannot be removed: they are only
+// removed explicitly when the root is a plain directory, as used by tests.
+func removeTree(path string) error {
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		p := filepath.Join(path, entry.Name())
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		case entry.IsDir():
+			// the default groups cannot be removed, the removal of the group fails if an other
+			// group is left
+			_ = removeTree(p)
+		default:
+			_ = os.Remove(p)
+		}
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,715 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lio implements a backend that provisions the LUNs on the Linux kernel target (LIO) of the
+// host it runs on, through its configfs interface. Each LUN has its own iSCSI target, which exports
+// a fileio backstore backed by a sparse file, or a block backstore backed by an LVM logical volume.
+package lio
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	klog "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+// Name is the name the backend is registered with
+const Name = "lio"
+
+const (
+	// BackstoreFileIO backs the LUNs with sparse files
+	BackstoreFileIO = "fileio"
+	// BackstoreBlock backs the LUNs with LVM logical volumes
+	BackstoreBlock = "block"
+
+	defaultRoot      = "/sys/kernel/config/target"
+	defaultFileIODir = "/var/lib/iscsi.csi.k8s.io/fileio"
+
+	// the configfs groups of the backstores, below core/
+	fileIOHBA = "fileio_0"
+	blockHBA  = "iblock_0"
+	// every target has a single portal group, which exports its LUN as LUN 0
+	tpgName = "tpgt_1"
+	lunName = "lun_0"
+
+	// the capacity of the LUNs is a multiple of sizeAlignment
+	sizeAlignment int64 = 1 << 20
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func init() {
+	backend.Register(Name, func(config []byte) (backend.Backend, error) {
+		cfg := Config{}
+		if err := yaml.UnmarshalStrict(config, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+		return New(cfg, exec.New())
+	})
+}
+
+// Config is the configuration of the LIO backend
+type Config struct {
+	// Root is the LIO configfs directory
+	Root string `json:"root"`
+	// IQNPrefix is the prefix of the IQN of the targets, which is suffixed with the LUN name
+	IQNPrefix string `json:"iqnPrefix"`
+	// Portals are the addresses the targets listen on, as ip:port
+	Portals []string `json:"portals"`
+	// Backstore is the type of backstore of the LUNs, fileio or block
+	Backstore string `json:"backstore"`
+	// FileIODir is the directory of the files of the fileio backstores
+	FileIODir string `json:"fileioDir"`
+	// VolumeGroup is the LVM volume group of the block backstores
+	VolumeGroup string `json:"volumeGroup"`
+	// ThinPool is the LVM thin pool of the block backstores, in VolumeGroup. The block backstores
+	// only support snapshots if they are thin.
+	ThinPool string `json:"thinPool"`
+	// ACLs restricts the access to the LUNs to the initiators they were granted to. Without ACLs,
+	// any initiator can access any LUN.
+	ACLs bool `json:"acls"`
+	// CHAP gives every initiator granted access to a LUN its own CHAP credentials
+	CHAP bool `json:"chap"`
+	// Topology are the segments the LUNs are accessible from, empty for every node
+	Topology []backend.Topology `json:"topology"`
+}
+
+// Backend provisions LUNs on LIO
+type Backend struct {
+	config Config
+	exec   exec.Interface
+
+	// serializes the changes of the configfs tree
+	lock sync.Mutex
+}
+
+var _ backend.Backend = &Backend{}
+
+// New creates a LIO backend
+func New(config Config, executor exec.Interface) (*Backend, error) {
+	if config.Root == "" {
+		config.Root = defaultRoot
+	}
+	if config.IQNPrefix == "" {
+		return nil, fmt.Errorf("iqnPrefix is required")
+	}
+	if len(config.Portals) == 0 {
+		return nil, fmt.Errorf("at least one portal is required")
+	}
+	switch config.Backstore {
+	case "", BackstoreFileIO:
+		config.Backstore = BackstoreFileIO
+		if config.FileIODir == "" {
+			config.FileIODir = defaultFileIODir
+		}
+		if err := os.MkdirAll(config.FileIODir, 0o750); err != nil {
+			return nil, err
+		}
+	case BackstoreBlock:
+		if config.VolumeGroup == "" {
+			return nil, fmt.Errorf("volumeGroup is required with the %s backstore", BackstoreBlock)
+		}
+	default:
+		return nil, fmt.Errorf("unknown backstore %q, expected %s or %s", config.Backstore, BackstoreFileIO, BackstoreBlock)
+	}
+	if config.CHAP && !config.ACLs {
+		return nil, fmt.Errorf("chap requires acls")
+	}
+
+	return &Backend{config: config, exec: executor}, nil
+}
+
+func (b *Backend) Capabilities() backend.Capabilities {
+	return backend.Capabilities{
+		// LIO only picks up the new size of block devices
+		Expand:        b.config.Backstore == BackstoreBlock,
+		Snapshot:      b.snapshotsSupported(),
+		ListSnapshots: b.snapshotsSupported(),
+		Clone:         b.snapshotsSupported(),
+		Capacity:      true,
+		ACL:           b.config.ACLs,
+		Get:           true,
+		List:          true,
+		Hosts:         b.config.ACLs,
+	}
+}
+
+func (b *Backend) CreateLUN(ctx context.Context, req *backend.CreateLUNRequest) (*backend.LUN, error) {
+	if !validName.MatchString(req.Name) {
+		return nil, fmt.Errorf("invalid LUN name %q", req.Name)
+	}
+	if !backend.Accessible(b.topology(), req.Requisite) {
+		return nil, fmt.Errorf("LUN %s: %w", req.Name, backend.ErrNotAccessible)
+	}
+	capacity := roundUp(req.RequiredBytes, sizeAlignment)
+	if req.LimitBytes > 0 && capacity > req.LimitBytes {
+		capacity = req.LimitBytes
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.backstoreExists(req.Name) {
+		size, err := b.backingSize(req.Name)
+		if err != nil {
+			return nil, err
+		}
+		if size < req.RequiredBytes || (req.LimitBytes > 0 && size > req.LimitBytes) {
+			return nil, fmt.Errorf("LUN %s of %d bytes: %w", req.Name, size, backend.ErrAlreadyExists)
+		}
+		capacity = size
+	} else if ref := req.SourceSnapshot; ref != nil {
+		snapshot, err := b.getSnapshot(ref.LUNID, ref.SnapshotID)
+		if err != nil {
+			return nil, err
+		}
+		capacity = max(capacity, snapshot.SizeBytes)
+		if req.LimitBytes > 0 && capacity > req.LimitBytes {
+			return nil, fmt.Errorf("snapshot %s of %d bytes exceeds the limit of %d bytes", ref.SnapshotID, snapshot.SizeBytes, req.LimitBytes)
+		}
+		if err := b.restoreBacking(req.Name, capacity, snapshot); err != nil {
+			return nil, err
+		}
+	} else if req.SourceLUNID != "" {
+		if !b.snapshotsSupported() {
+			return nil, fmt.Errorf("clones of block backstores without thinPool: %w", backend.ErrNotSupported)
+		}
+		if !b.backstoreExists(req.SourceLUNID) {
+			return nil, fmt.Errorf("LUN %s: %w", req.SourceLUNID, backend.ErrNotFound)
+		}
+		sourceSize, err := b.backingSize(req.SourceLUNID)
+		if err != nil {
+			return nil, err
+		}
+		capacity = max(capacity, sourceSize)
+		if req.LimitBytes > 0 && capacity > req.LimitBytes {
+			return nil, fmt.Errorf("LUN %s of %d bytes exceeds the limit of %d bytes", req.SourceLUNID, sourceSize, req.LimitBytes)
+		}
+		source := req.SourceLUNID
+		if b.config.Backstore == BackstoreFileIO {
+			source = b.backingFile(req.SourceLUNID)
+		}
+		if err := b.cloneBacking(req.Name, capacity, source, sourceSize); err != nil {
+			return nil, fmt.Errorf("failed to clone LUN %s: %v", req.SourceLUNID, err)
+		}
+	} else if err := b.createBacking(req.Name, capacity); err != nil {
+		return nil, err
+	}
+
+	// the steps are idempotent, so that a creation that failed midway is completed when retried
+	if err := b.createBackstore(req.Name); err != nil {
+		return nil, err
+	}
+	if err := b.createTarget(req.Name); err != nil {
+		return nil, err
+	}
+
+	klog.V(2).Infof("lio: created LUN %s of %d bytes", req.Name, capacity)
+	return b.lun(req.Name, capacity), nil
+}
+
+func (b *Backend) DeleteLUN(ctx context.Context, lunID string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.backstoreExists(lunID) && !b.backingExists(lunID) && !exists(b.targetDir(lunID)) {
+		return fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+
+	// the target goes first, as its LUN refers to the backstore
+	if err := b.removeTarget(lunID); err != nil {
+		return err
+	}
+	if err := removeTree(b.backstoreDir(lunID)); err != nil {
+		return fmt.Errorf("failed to remove backstore of LUN %s: %v", lunID, err)
+	}
+	if err := b.removeBacking(lunID); err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("lio: deleted LUN %s", lunID)
+	return nil
+}
+
+func (b *Backend) GetLUN(ctx context.Context, lunID string) (*backend.LUN, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.backstoreExists(lunID) {
+		return nil, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	return b.describeLUN(lunID)
+}
+
+// ListLUNs returns the LUNs with a backstore and a target created by the backend
+func (b *Backend) ListLUNs(ctx context.Context) ([]*backend.LUN, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entries, err := os.ReadDir(b.hbaDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	luns := []*backend.LUN{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !validName.MatchString(name) || !exists(b.targetDir(name)) {
+			continue
+		}
+		lun, err := b.describeLUN(name)
+		if err != nil {
+			return nil, err
+		}
+		luns = append(luns, lun)
+	}
+	return luns, nil
+}
+
+func (b *Backend) ExpandLUN(ctx context.Context, lunID string, capacityBytes int64) (int64, error) {
+	if b.config.Backstore != BackstoreBlock {
+		return 0, fmt.Errorf("expansion of %s backstores: %w", b.config.Backstore, backend.ErrNotSupported)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.backstoreExists(lunID) {
+		return 0, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	size, err := b.backingSize(lunID)
+	if err != nil {
+		return 0, err
+	}
+	if capacityBytes <= size {
+		return size, nil
+	}
+
+	capacity := roundUp(capacityBytes, sizeAlignment)
+	out, err := b.exec.Command("lvextend", "-L", fmt.Sprintf("%db", capacity), b.logicalVolume(lunID)).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("lvextend of %s failed: %v, output: %s", b.logicalVolume(lunID), err, out)
+	}
+	return b.backingSize(lunID)
+}
+
+func (b *Backend) GrantInitiator(ctx context.Context, lunID string, host backend.Host) (*backend.Access, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.backstoreExists(lunID) {
+		return nil, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	access := &backend.Access{Target: b.target(lunID)}
+	if !b.config.ACLs {
+		return access, nil
+	}
+	if host.InitiatorIQN == "" {
+		return nil, fmt.Errorf("initiator IQN of node %s is unknown", host.NodeID)
+	}
+
+	aclDir := b.aclDir(lunID, host.InitiatorIQN)
+	mappedLUNDir := filepath.Join(aclDir, lunName)
+	if err := os.MkdirAll(mappedLUNDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create ACL of %s: %v", host.InitiatorIQN, err)
+	}
+	if err := ensureSymlink(filepath.Join(b.tpgDir(lunID), "lun", lunName), filepath.Join(mappedLUNDir, lunName)); err != nil {
+		return nil, err
+	}
+	if err := ensureAttr(filepath.Join(aclDir, "tag"), host.NodeID); err != nil {
+		return nil, err
+	}
+
+	if b.config.CHAP {
+		chap, err := ensureCHAP(filepath.Join(aclDir, "auth"), host)
+		if err != nil {
+			return nil, err
+		}
+		access.CHAP = chap
+	}
+
+	klog.V(2).Infof("lio: granted initiator %s access to LUN %s", host.InitiatorIQN, lunID)
+	return access, nil
+}
+
+func (b *Backend) RevokeInitiator(ctx context.Context, lunID string, host backend.Host) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.backstoreExists(lunID) {
+		return fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	if !b.config.ACLs || host.InitiatorIQN == "" {
+		return nil
+	}
+	if err := removeTree(b.aclDir(lunID, host.InitiatorIQN)); err != nil {
+		return fmt.Errorf("failed to remove ACL of %s: %v", host.InitiatorIQN, err)
+	}
+
+	klog.V(2).Infof("lio: revoked access of initiator %s to LUN %s", host.InitiatorIQN, lunID)
+	return nil
+}
+
+func (b *Backend) Capacity(ctx context.Context, parameters map[string]string, segment backend.Topology) (int64, error) {
+	if segment != nil && !backend.AccessibleFrom(b.topology(), segment) {
+		return 0, nil
+	}
+	if b.config.Backstore == BackstoreFileIO {
+		return freeBytes(b.config.FileIODir)
+	}
+	if b.config.ThinPool != "" {
+		return b.thinPoolFreeBytes()
+	}
+
+	out, err := b.exec.Command("vgs", "--noheadings", "--units", "b", "--nosuffix", "-o", "vg_free", b.config.VolumeGroup).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("vgs of %s failed: %v, output: %s", b.config.VolumeGroup, err, out)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+func (b *Backend) lun(name string, capacity int64) *backend.LUN {
+	return &backend.LUN{
+		ID:            name,
+		Name:          name,
+		CapacityBytes: capacity,
+		Target:        b.target(name),
+		Topology:      b.topology(),
+	}
+}
+
+// topology returns the segments the LUNs are accessible from, nil for every node
+func (b *Backend) topology() []backend.Topology {
+	if len(b.config.Topology) == 0 {
+		return nil
+	}
+	return b.config.Topology
+}
+
+// describeLUN returns a LUN with the hosts granted access to it and its adverse conditions
+func (b *Backend) describeLUN(name string) (*backend.LUN, error) {
+	var health []backend.HealthCondition
+	var size int64
+	if b.backingExists(name) {
+		var err error
+		if size, err = b.backingSize(name); err != nil {
+			return nil, err
+		}
+	} else {
+		health = append(health, backend.HealthCondition{
+			Status:  backend.HealthInaccessible,
+			Reason:  "BackingMissing",
+			Message: "the file or logical volume of the LUN is missing",
+		})
+	}
+	if readAttr(filepath.Join(b.backstoreDir(name), "enable")) != "1" {
+		health = append(health, backend.HealthCondition{
+			Status:  backend.HealthInaccessible,
+			Reason:  "BackstoreDisabled",
+			Message: "the backstore of the LUN is not enabled",
+		})
+	}
+	if readAttr(filepath.Join(b.tpgDir(name), "enable")) != "1" {
+		health = append(health, backend.HealthCondition{
+			Status:  backend.HealthInaccessible,
+			Reason:  "TargetDisabled",
+			Message: "the target of the LUN is not enabled",
+		})
+	}
+	var missingPortals []string
+	for _, portal := range b.config.Portals {
+		if !exists(filepath.Join(b.tpgDir(name), "np", portal)) {
+			missingPortals = append(missingPortals, portal)
+		}
+	}
+	if len(missingPortals) > 0 {
+		health = append(health, backend.HealthCondition{
+			Status:  backend.HealthDegraded,
+			Reason:  "MissingPortals",
+			Message: fmt.Sprintf("the target of the LUN does not listen on %s", strings.Join(missingPortals, ", ")),
+		})
+	}
+
+	lun := b.lun(name, size)
+	lun.Health = health
+	if b.config.ACLs {
+		acls, err := os.ReadDir(filepath.Join(b.tpgDir(name), "acls"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, acl := range acls {
+			lun.Hosts = append(lun.Hosts, backend.Host{
+				// the node ID is kept in the tag of the ACL
+				NodeID:       readAttr(filepath.Join(b.tpgDir(name), "acls", acl.Name(), "tag")),
+				InitiatorIQN: acl.Name(),
+			})
+		}
+	}
+	return lun, nil
+}
+
+func (b *Backend) target(name string) backend.Target {
+	return backend.Target{
+		Portals: append([]string{}, b.config.Portals...),
+		IQN:     b.iqn(name),
+		LUN:     0,
+	}
+}
+
+func (b *Backend) iqn(name string) string {
+	// IQNs are lowercase
+	return strings.ToLower(fmt.Sprintf("%s:%s", b.config.IQNPrefix, name))
+}
+
+// createBacking creates the file or the logical volume of a LUN
+func (b *Backend) createBacking(name string, capacity int64) error {
+	if b.config.Backstore == BackstoreFileIO {
+		f, err := os.OpenFile(b.backingFile(name), os.O_RDWR|os.O_CREATE, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// the file stays sparse until the LUN is written
+		return f.Truncate(capacity)
+	}
+
+	if b.backingExists(name) {
+		return nil
+	}
+	args := []string{"-y", "-L", fmt.Sprintf("%db", capacity), "-n", name, b.config.VolumeGroup}
+	if b.config.ThinPool != "" {
+		args = []string{"-y", "-V", fmt.Sprintf("%db", capacity), "-T", b.config.VolumeGroup + "/" + b.config.ThinPool, "-n", name}
+	}
+	out, err := b.exec.Command("lvcreate", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("lvcreate of %s failed: %v, output: %s", b.logicalVolume(name), err, out)
+	}
+	return nil
+}
+
+func (b *Backend) removeBacking(name string) error {
+	if b.config.Backstore == BackstoreFileIO {
+		if err := os.Remove(b.backingFile(name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if !b.backingExists(name) {
+		return nil
+	}
+	out, err := b.exec.Command("lvremove", "-y", b.logicalVolume(name)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("lvremove of %s failed: %v, output: %s", b.logicalVolume(name), err, out)
+	}
+	return nil
+}
+
+func (b *Backend) backingExists(name string) bool {
+	if b.config.Backstore == BackstoreFileIO {
+		return exists(b.backingFile(name))
+	}
+	return exists(b.logicalVolume(name))
+}
+
+func (b *Backend) backingSize(name string) (int64, error) {
+	if b.config.Backstore == BackstoreFileIO {
+		fi, err := os.Stat(b.backingFile(name))
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+
+	out, err := b.exec.Command("blockdev", "--getsize64", b.logicalVolume(name)).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("blockdev --getsize64 of %s failed: %v, output: %s", b.logicalVolume(name), err, out)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+func (b *Backend) backingFile(name string) string {
+	return filepath.Join(b.config.FileIODir, name+".img")
+}
+
+func (b *Backend) logicalVolume(name string) string {
+	return filepath.Join("/dev", b.config.VolumeGroup, name)
+}
+
+// hbaDir is the directory of the backstores of the LUNs
+func (b *Backend) hbaDir() string {
+	if b.config.Backstore == BackstoreFileIO {
+		return filepath.Join(b.config.Root, "core", fileIOHBA)
+	}
+	return filepath.Join(b.config.Root, "core", blockHBA)
+}
+
+func (b *Backend) backstoreDir(name string) string {
+	return filepath.Join(b.hbaDir(), name)
+}
+
+func (b *Backend) backstoreExists(name string) bool {
+	return exists(b.backstoreDir(name))
+}
+
+// createBackstore creates and enables the backstore of a LUN, on its file or logical volume
+func (b *Backend) createBackstore(name string) error {
+	dir := b.backstoreDir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create backstore of LUN %s: %v", name, err)
+	}
+	if readAttr(filepath.Join(dir, "enable")) == "1" {
+		return nil
+	}
+
+	// without fd_dev_size, the size of the file is the size of the LUN
+	control := "fd_dev_name=" + b.backingFile(name)
+	if b.config.Backstore == BackstoreBlock {
+		control = "udev_path=" + b.logicalVolume(name)
+	}
+	if err := writeAttr(filepath.Join(dir, "control"), control); err != nil {
+		return err
+	}
+	return writeAttr(filepath.Join(dir, "enable"), "1")
+}
+
+func (b *Backend) targetDir(name string) string {
+	return filepath.Join(b.config.Root, "iscsi", b.iqn(name))
+}
+
+func (b *Backend) tpgDir(name string) string {
+	return filepath.Join(b.targetDir(name), tpgName)
+}
+
+func (b *Backend) aclDir(name, initiatorIQN string) string {
+	return filepath.Join(b.tpgDir(name), "acls", initiatorIQN)
+}
+
+// createTarget creates the target of a LUN, with its portals, and exports the backstore of the LUN
+func (b *Backend) createTarget(name string) error {
+	tpg := b.tpgDir(name)
+	for _, portal := range b.config.Portals {
+		if err := os.MkdirAll(filepath.Join(tpg, "np", portal), 0o755); err != nil {
+			return fmt.Errorf("failed to create portal %s of LUN %s: %v", portal, name, err)
+		}
+	}
+
+	lunDir := filepath.Join(tpg, "lun", lunName)
+	if err := os.MkdirAll(lunDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create LUN of target %s: %v", b.iqn(name), err)
+	}
+	if err := ensureSymlink(b.backstoreDir(name), filepath.Join(lunDir, name)); err != nil {
+		return err
+	}
+
+	attrs := []struct{ name, value string }{
+		{"attrib/authentication", "0"},
+		{"attrib/generate_node_acls", "0"},
+		{"attrib/demo_mode_write_protect", "1"},
+	}
+	if !b.config.ACLs {
+		// any initiator gets a read-write dynamic ACL
+		attrs = []struct{ name, value string }{
+			{"attrib/authentication", "0"},
+			{"attrib/generate_node_acls", "1"},
+			{"attrib/cache_dynamic_acls", "1"},
+			{"attrib/demo_mode_write_protect", "0"},
+		}
+	} else if b.config.CHAP {
+		attrs[0].value = "1"
+	}
+	attrs = append(attrs, struct{ name, value string }{"enable", "1"})
+	for _, attr := range attrs {
+		if err := ensureAttr(filepath.Join(tpg, attr.name), attr.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeTarget removes the target of a LUN, after its ACLs and its LUN which refer to each other
+func (b *Backend) removeTarget(name string) error {
+	tpg := b.tpgDir(name)
+	acls, err := os.ReadDir(filepath.Join(tpg, "acls"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, acl := range acls {
+		if err := removeTree(filepath.Join(tpg, "acls", acl.Name())); err != nil {
+			return fmt.Errorf("failed to remove ACL %s of LUN %s: %v", acl.Name(), name, err)
+		}
+	}
+	for _, dir := range []string{filepath.Join(tpg, "lun", lunName), filepath.Join(tpg, "np"), b.targetDir(name)} {
+		if err := removeTree(dir); err != nil {
+			return fmt.Errorf("failed to remove target of LUN %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ensureCHAP sets the CHAP credentials of an ACL, unless they were already set
+func ensureCHAP(authDir string, host backend.Host) (*backend.CHAP, error) {
+	user := readAttr(filepath.Join(authDir, "userid"))
+	password := readAttr(filepath.Join(authDir, "password"))
+	if user != "" && user != "NULL" && password != "" && password != "NULL" {
+		return &backend.CHAP{User: user, Password: password}, nil
+	}
+
+	secret := make([]byte, 12)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	chap := &backend.CHAP{User: host.NodeID, Password: hex.EncodeToString(secret)}
+	if chap.User == "" {
+		chap.User = host.InitiatorIQN
+	}
+	if err := os.MkdirAll(authDir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := writeAttr(filepath.Join(authDir, "userid"), chap.User); err != nil {
+		return nil, err
+	}
+	if err := writeAttr(filepath.Join(authDir, "password"), chap.Password); err != nil {
+		return nil, err
+	}
+	return chap, nil
+}
+
+func roundUp(size, alignment int64) int64 {
+	if size <= 0 {
+		return alignment
+	}
+	return (size + alignment - 1) / alignment * alignment
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
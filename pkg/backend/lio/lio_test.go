@@ -0,0 +1,269 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lio
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+)
+
+const (
+	testIQN    = "iqn.2026-01.io.example:pvc-1"
+	testTarget = "iscsi/" + testIQN + "/tpgt_1/"
+)
+
+func newTestBackend(t *testing.T, acls, chap bool) (*Backend, string) {
+	t.Helper()
+	root := t.TempDir()
+	b, err := New(Config{
+		Root:      filepath.Join(root, "target"),
+		IQNPrefix: "iqn.2026-01.io.example",
+		Portals:   []string{"10.0.0.1:3260", "10.0.1.1:3260"},
+		FileIODir: filepath.Join(root, "fileio"),
+		ACLs:      acls,
+		CHAP:      chap,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, root
+}
+
+// readTree returns the configfs tree below root: the directories end with a slash, the attributes
+// map to their value and the links to their target, relative to root
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			tree[rel] = "-> " + strings.TrimPrefix(target, root+"/")
+		case entry.IsDir():
+			tree[rel+"/"] = ""
+		default:
+			tree[rel] = readAttr(path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+// checkTree checks that the configfs tree holds the expected entries, the directories are left out
+func checkTree(t *testing.T, b *Backend, expected map[string]string) {
+	t.Helper()
+	tree := readTree(t, b.config.Root)
+	for path := range tree {
+		if strings.HasSuffix(path, "/") {
+			delete(tree, path)
+		}
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("unexpected configfs tree:\n%v\nexpected:\n%v", tree, expected)
+	}
+}
+
+func TestCreateLUN(t *testing.T) {
+	tests := []struct {
+		name  string
+		acls  bool
+		chap  bool
+		attrs map[string]string
+	}{
+		{
+			name: "without ACLs",
+			attrs: map[string]string{
+				"attrib/authentication":          "0",
+				"attrib/generate_node_acls":      "1",
+				"attrib/cache_dynamic_acls":      "1",
+				"attrib/demo_mode_write_protect": "0",
+			},
+		},
+		{
+			name: "with ACLs",
+			acls: true,
+			attrs: map[string]string{
+				"attrib/authentication":          "0",
+				"attrib/generate_node_acls":      "0",
+				"attrib/demo_mode_write_protect": "1",
+			},
+		},
+		{
+			name: "with CHAP",
+			acls: true,
+			chap: true,
+			attrs: map[string]string{
+				"attrib/authentication":          "1",
+				"attrib/generate_node_acls":      "0",
+				"attrib/demo_mode_write_protect": "1",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, root := newTestBackend(t, test.acls, test.chap)
+			req := &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 3<<20 + 1}
+			lun, err := b.CreateLUN(context.Background(), req)
+			if err != nil {
+				t.Fatalf("failed to create LUN: %v", err)
+			}
+			if lun.ID != "pvc-1" || lun.CapacityBytes != 4<<20 || lun.Target.IQN != testIQN || len(lun.Target.Portals) != 2 {
+				t.Errorf("unexpected LUN %+v", lun)
+			}
+
+			backingFile := filepath.Join(root, "fileio", "pvc-1.img")
+			expected := map[string]string{
+				"core/fileio_0/pvc-1/control":  "fd_dev_name=" + backingFile,
+				"core/fileio_0/pvc-1/enable":   "1",
+				testTarget + "lun/lun_0/pvc-1": "-> core/fileio_0/pvc-1",
+				testTarget + "enable":          "1",
+			}
+			for attr, value := range test.attrs {
+				expected[testTarget+attr] = value
+			}
+			checkTree(t, b, expected)
+			for _, portal := range b.config.Portals {
+				if !exists(filepath.Join(b.tpgDir("pvc-1"), "np", portal)) {
+					t.Errorf("portal %s is missing", portal)
+				}
+			}
+			if fi, err := os.Stat(backingFile); err != nil || fi.Size() != 4<<20 {
+				t.Errorf("unexpected backing file %v, err: %v", fi, err)
+			}
+
+			// the creation is idempotent
+			if _, err := b.CreateLUN(context.Background(), req); err != nil {
+				t.Errorf("failed to create LUN again: %v", err)
+			}
+			checkTree(t, b, expected)
+			if _, err := b.CreateLUN(context.Background(), &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 8 << 20}); !errors.Is(err, backend.ErrAlreadyExists) {
+				t.Errorf("expected %v for a larger LUN, got %v", backend.ErrAlreadyExists, err)
+			}
+		})
+	}
+}
+
+func TestDeleteLUN(t *testing.T) {
+	b, root := newTestBackend(t, true, false)
+	ctx := context.Background()
+	if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-2", RequiredBytes: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GrantInitiator(ctx, "pvc-1", backend.Host{NodeID: "node1", InitiatorIQN: "iqn.2026-01.io.example:node1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.DeleteLUN(ctx, "pvc-1"); err != nil {
+		t.Fatalf("failed to delete LUN: %v", err)
+	}
+	for path := range readTree(t, b.config.Root) {
+		if strings.Contains(path, "pvc-1") {
+			t.Errorf("%s is left", path)
+		}
+	}
+	if exists(filepath.Join(root, "fileio", "pvc-1.img")) {
+		t.Errorf("the backing file is left")
+	}
+	if _, err := b.GetLUN(ctx, "pvc-2"); err != nil {
+		t.Errorf("the other LUN is gone: %v", err)
+	}
+	if err := b.DeleteLUN(ctx, "pvc-1"); !errors.Is(err, backend.ErrNotFound) {
+		t.Errorf("expected %v, got %v", backend.ErrNotFound, err)
+	}
+}
+
+func TestGrantRevokeInitiator(t *testing.T) {
+	b, _ := newTestBackend(t, true, true)
+	ctx := context.Background()
+	if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	host := backend.Host{NodeID: "node1", InitiatorIQN: "iqn.2026-01.io.example:node1"}
+	acl := testTarget + "acls/" + host.InitiatorIQN + "/"
+
+	access, err := b.GrantInitiator(ctx, "pvc-1", host)
+	if err != nil {
+		t.Fatalf("failed to grant access: %v", err)
+	}
+	if access.Target.IQN != testIQN || access.CHAP == nil || access.CHAP.User != "node1" || access.CHAP.Password == "" {
+		t.Errorf("unexpected access %+v", access)
+	}
+	tree := readTree(t, b.config.Root)
+	expected := map[string]string{
+		acl + "lun_0/lun_0":   "-> " + testTarget + "lun/lun_0",
+		acl + "tag":           "node1",
+		acl + "auth/userid":   "node1",
+		acl + "auth/password": access.CHAP.Password,
+	}
+	for path, value := range expected {
+		if tree[path] != value {
+			t.Errorf("expected %s to be %q, got %q", path, value, tree[path])
+		}
+	}
+
+	// the grant is idempotent and keeps the credentials
+	again, err := b.GrantInitiator(ctx, "pvc-1", host)
+	if err != nil {
+		t.Fatalf("failed to grant access again: %v", err)
+	}
+	if !reflect.DeepEqual(again.CHAP, access.CHAP) {
+		t.Errorf("expected the same credentials, got %+v", again.CHAP)
+	}
+	lun, err := b.GetLUN(ctx, "pvc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lun.Hosts, []backend.Host{host}) {
+		t.Errorf("expected hosts %v, got %v", []backend.Host{host}, lun.Hosts)
+	}
+
+	if err := b.RevokeInitiator(ctx, "pvc-1", host); err != nil {
+		t.Fatalf("failed to revoke access: %v", err)
+	}
+	for path := range readTree(t, b.config.Root) {
+		if strings.HasPrefix(path, testTarget+"acls/") && path != testTarget+"acls/" {
+			t.Errorf("%s is left", path)
+		}
+	}
+	if err := b.RevokeInitiator(ctx, "pvc-1", host); err != nil {
+		t.Errorf("failed to revoke access again: %v", err)
+	}
+	if _, err := b.GrantInitiator(ctx, "pvc-1", backend.Host{NodeID: "node2"}); err == nil {
+		t.Errorf("expected the grant to a node without initiator name to fail")
+	}
+}
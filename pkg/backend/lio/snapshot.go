@@ -0,0 +1,332 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+	klog "k8s.io/klog/v2"
+)
+
+// The snapshots of the fileio backstores are sparse copies of their files, in a directory per LUN
+// below the snapshots directory of FileIODir. The snapshots of the thin block backstores are thin
+// snapshots of their logical volumes, named after the snapshot with the snapshotLVPrefix prefix.
+const (
+	snapshotsDir      = "snapshots"
+	snapshotLVPrefix  = "snap."
+	lvsTimeLayout     = "2006-01-02 15:04:05 -0700"
+	lvsFieldSeparator = "|"
+)
+
+func (b *Backend) snapshotsSupported() bool {
+	return b.config.Backstore == BackstoreFileIO || b.config.ThinPool != ""
+}
+
+// CreateSnapshot copies the file of a fileio LUN, which is only consistent if the LUN is not written
+// meanwhile, or takes a thin snapshot of the logical volume of a block LUN
+func (b *Backend) CreateSnapshot(ctx context.Context, lunID, name string) (*backend.Snapshot, error) {
+	if !b.snapshotsSupported() {
+		return nil, fmt.Errorf("snapshots of block backstores without thinPool: %w", backend.ErrNotSupported)
+	}
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid snapshot name %q", name)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.backstoreExists(lunID) {
+		return nil, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	snapshots, err := b.listSnapshots("")
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID != name {
+			continue
+		}
+		if snapshot.SourceLUNID != lunID {
+			return nil, fmt.Errorf("snapshot %s of LUN %s: %w", name, snapshot.SourceLUNID, backend.ErrAlreadyExists)
+		}
+		return snapshot, nil
+	}
+
+	if b.config.Backstore == BackstoreFileIO {
+		err = b.copyFile(b.backingFile(lunID), b.snapshotFile(lunID, name))
+	} else {
+		err = b.lvcreateSnapshot(b.config.VolumeGroup+"/"+lunID, snapshotLVPrefix+name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot LUN %s: %v", lunID, err)
+	}
+
+	klog.V(2).Infof("lio: created snapshot %s of LUN %s", name, lunID)
+	return b.getSnapshot(lunID, name)
+}
+
+func (b *Backend) DeleteSnapshot(ctx context.Context, lunID, snapshotID string) error {
+	if !b.snapshotsSupported() {
+		return fmt.Errorf("snapshots of block backstores without thinPool: %w", backend.ErrNotSupported)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, err := b.getSnapshot(lunID, snapshotID); err != nil {
+		return err
+	}
+	if b.config.Backstore == BackstoreFileIO {
+		if err := os.Remove(b.snapshotFile(lunID, snapshotID)); err != nil {
+			return err
+		}
+		// the directory of the LUN goes with its last snapshot
+		_ = os.Remove(filepath.Dir(b.snapshotFile(lunID, snapshotID)))
+	} else {
+		lv := b.config.VolumeGroup + "/" + snapshotLVPrefix + snapshotID
+		if out, err := b.exec.Command("lvremove", "-y", lv).CombinedOutput(); err != nil {
+			return fmt.Errorf("lvremove of %s failed: %v, output: %s", lv, err, out)
+		}
+	}
+
+	klog.V(2).Infof("lio: deleted snapshot %s of LUN %s", snapshotID, lunID)
+	return nil
+}
+
+func (b *Backend) ListSnapshots(ctx context.Context, lunID string) ([]*backend.Snapshot, error) {
+	if !b.snapshotsSupported() {
+		return nil, fmt.Errorf("snapshots of block backstores without thinPool: %w", backend.ErrNotSupported)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.listSnapshots(lunID)
+}
+
+func (b *Backend) getSnapshot(lunID, snapshotID string) (*backend.Snapshot, error) {
+	snapshots, err := b.listSnapshots(lunID)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == snapshotID {
+			return snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot %s of LUN %s: %w", snapshotID, lunID, backend.ErrNotFound)
+}
+
+// listSnapshots returns the snapshots of a LUN, or of all the LUNs if lunID is empty, by ID
+func (b *Backend) listSnapshots(lunID string) ([]*backend.Snapshot, error) {
+	var snapshots []*backend.Snapshot
+	var err error
+	if b.config.Backstore == BackstoreFileIO {
+		snapshots, err = b.listSnapshotFiles(lunID)
+	} else {
+		snapshots, err = b.listSnapshotLVs(lunID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ID < snapshots[j].ID })
+	return snapshots, nil
+}
+
+func (b *Backend) listSnapshotFiles(lunID string) ([]*backend.Snapshot, error) {
+	pattern := filepath.Join(b.config.FileIODir, snapshotsDir, "*", "*.img")
+	if lunID != "" {
+		pattern = b.snapshotFile(lunID, "*")
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*backend.Snapshot{}
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".img")
+		snapshots = append(snapshots, &backend.Snapshot{
+			ID:           name,
+			Name:         name,
+			SourceLUNID:  filepath.Base(filepath.Dir(file)),
+			SizeBytes:    fi.Size(),
+			CreationTime: fi.ModTime(),
+			ReadyToUse:   true,
+		})
+	}
+	return snapshots, nil
+}
+
+func (b *Backend) listSnapshotLVs(lunID string) ([]*backend.Snapshot, error) {
+	out, err := b.exec.Command("lvs", "--noheadings", "--units", "b", "--nosuffix", "--separator", lvsFieldSeparator,
+		"-o", "lv_name,origin,lv_size,lv_time", b.config.VolumeGroup).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("lvs of %s failed: %v, output: %s", b.config.VolumeGroup, err, out)
+	}
+
+	snapshots := []*backend.Snapshot{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), lvsFieldSeparator)
+		if len(fields) != 4 || !strings.HasPrefix(fields[0], snapshotLVPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(fields[0], snapshotLVPrefix)
+		origin := fields[1]
+		if origin == "" || (lunID != "" && origin != lunID) {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size of logical volume %s: %v", fields[0], err)
+		}
+		creationTime, err := time.Parse(lvsTimeLayout, fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of logical volume %s: %v", fields[0], err)
+		}
+		snapshots = append(snapshots, &backend.Snapshot{
+			ID:           name,
+			Name:         name,
+			SourceLUNID:  origin,
+			SizeBytes:    size,
+			CreationTime: creationTime,
+			ReadyToUse:   true,
+		})
+	}
+	return snapshots, nil
+}
+
+// restoreBacking creates the file or the logical volume of a LUN from a snapshot
+func (b *Backend) restoreBacking(name string, capacity int64, snapshot *backend.Snapshot) error {
+	source := snapshotLVPrefix + snapshot.ID
+	if b.config.Backstore == BackstoreFileIO {
+		source = b.snapshotFile(snapshot.SourceLUNID, snapshot.ID)
+	}
+	if err := b.cloneBacking(name, capacity, source, snapshot.SizeBytes); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %v", snapshot.ID, err)
+	}
+	return nil
+}
+
+// cloneBacking creates the file or the logical volume of a LUN from a file, or from a logical volume
+// of the volume group, of sourceSize bytes
+func (b *Backend) cloneBacking(name string, capacity int64, source string, sourceSize int64) error {
+	if b.config.Backstore == BackstoreFileIO {
+		if err := b.copyFile(source, b.backingFile(name)); err != nil {
+			return err
+		}
+		return os.Truncate(b.backingFile(name), capacity)
+	}
+
+	if !b.backingExists(name) {
+		if err := b.lvcreateSnapshot(b.config.VolumeGroup+"/"+source, name); err != nil {
+			return err
+		}
+	}
+	if capacity > sourceSize {
+		lv := b.logicalVolume(name)
+		if out, err := b.exec.Command("lvextend", "-L", fmt.Sprintf("%db", capacity), lv).CombinedOutput(); err != nil {
+			return fmt.Errorf("lvextend of %s failed: %v, output: %s", lv, err, out)
+		}
+	}
+	return nil
+}
+
+// lvcreateSnapshot takes an active thin snapshot of a logical volume
+func (b *Backend) lvcreateSnapshot(origin, name string) error {
+	// thin snapshots are not activated by default, -kn makes them activated like other volumes
+	out, err := b.exec.Command("lvcreate", "-y", "-s", "-kn", "-n", name, origin).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("lvcreate of snapshot %s of %s failed: %v, output: %s", name, origin, err, out)
+	}
+	return nil
+}
+
+// copyFile makes a sparse copy of a file, which only appears once complete
+func (b *Backend) copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	if err := out.Truncate(fi.Size()); err != nil {
+		return err
+	}
+	if err := lunio.CopySparse(out, in, fi.Size()); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (b *Backend) snapshotFile(lunID, name string) string {
+	return filepath.Join(b.config.FileIODir, snapshotsDir, lunID, name+".img")
+}
+
+// thinPoolFreeBytes returns the space left in the thin pool
+func (b *Backend) thinPoolFreeBytes() (int64, error) {
+	pool := b.config.VolumeGroup + "/" + b.config.ThinPool
+	out, err := b.exec.Command("lvs", "--noheadings", "--units", "b", "--nosuffix", "--separator", lvsFieldSeparator,
+		"-o", "lv_size,data_percent", pool).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("lvs of %s failed: %v, output: %s", pool, err, out)
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), lvsFieldSeparator)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("unexpected output of lvs of %s: %s", pool, out)
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	used, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return size - int64(float64(size)*used/100), nil
+}
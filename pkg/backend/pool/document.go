@@ -0,0 +1,147 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// document is a YAML or JSON document stored in a file or in a key of a ConfigMap
+type document interface {
+	// read returns the content of the document and its version, or no content if the document
+	// does not exist
+	read(ctx context.Context) ([]byte, string, error)
+	// write replaces the content of the document, it fails if the document was changed since
+	// the given version was read
+	write(ctx context.Context, data []byte, version string) error
+}
+
+// errConflict is returned by the writes of a document that was changed since it was read
+var errConflict = errors.New("the document was changed concurrently")
+
+type fileDocument struct {
+	path string
+}
+
+// read returns the content of the file, its version is the hash of the content
+func (d *fileDocument) read(ctx context.Context) ([]byte, string, error) {
+	data, err := os.ReadFile(d.path)
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentVersion(data), nil
+}
+
+// write replaces the file atomically. The file is only written by the controller service, which
+// serializes the writes, the version is checked against the changes of the file by hand.
+func (d *fileDocument) write(ctx context.Context, data []byte, version string) error {
+	_, currentVersion, err := d.read(ctx)
+	if err != nil {
+		return err
+	}
+	if currentVersion != version {
+		return fmt.Errorf("%s: %w", d.path, errConflict)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), filepath.Base(d.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path)
+}
+
+// contentVersion returns the version of a document stored without one
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type configMapDocument struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	key       string
+}
+
+func (d *configMapDocument) read(ctx context.Context) ([]byte, string, error) {
+	cm, err := d.client.CoreV1().ConfigMaps(d.namespace).Get(ctx, d.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(cm.Data[d.key]), cm.ResourceVersion, nil
+}
+
+func (d *configMapDocument) write(ctx context.Context, data []byte, version string) error {
+	if version == "" {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: d.namespace, Name: d.name},
+			Data:       map[string]string{d.key: string(data)},
+		}
+		_, err := d.client.CoreV1().ConfigMaps(d.namespace).Create(ctx, cm, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("ConfigMap %s/%s: %w", d.namespace, d.name, errConflict)
+		}
+		return err
+	}
+
+	cm, err := d.client.CoreV1().ConfigMaps(d.namespace).Get(ctx, d.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if cm.ResourceVersion != version {
+		return fmt.Errorf("ConfigMap %s/%s: %w", d.namespace, d.name, errConflict)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[d.key] = string(data)
+	_, err = d.client.CoreV1().ConfigMaps(d.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return fmt.Errorf("ConfigMap %s/%s: %w", d.namespace, d.name, errConflict)
+	}
+	return err
+}
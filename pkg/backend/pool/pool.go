@@ -0,0 +1,548 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pool implements a backend that hands out LUNs pre-provisioned by an administrator. The
+// pool of LUNs is read from a file or a ConfigMap, and the claims of the volumes on the LUNs are
+// recorded in an other file or ConfigMap, so that they survive restarts of the controller service.
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Name is the name the backend is registered with
+const Name = "pool"
+
+// SelectorParameter is the StorageClass parameter holding the label selector of the LUNs of its
+// volumes
+const SelectorParameter = "selector"
+
+// volumeIDSeparator separates the ID of a LUN of the pool from the name of the volume claiming it in
+// the IDs of the volumes, so that a LUN claimed again does not get the ID of the deleted volume
+const volumeIDSeparator = "@"
+
+func init() {
+	backend.Register(Name, func(config []byte) (backend.Backend, error) {
+		cfg := Config{}
+		if err := yaml.UnmarshalStrict(config, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+
+		var client kubernetes.Interface
+		if cfg.LUNsConfigMap != nil || cfg.ClaimsConfigMap != nil {
+			restConfig, err := rest.InClusterConfig()
+			if err != nil {
+				return nil, err
+			}
+			client, err = kubernetes.NewForConfig(restConfig)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return New(cfg, client)
+	})
+}
+
+// Config is the configuration of the pool backend. The LUNs come from one of LUNs, LUNsFile and
+// LUNsConfigMap, and the claims are stored in one of ClaimsFile and ClaimsConfigMap.
+type Config struct {
+	LUNs            []Entry       `json:"luns"`
+	LUNsFile        string        `json:"lunsFile"`
+	LUNsConfigMap   *ConfigMapKey `json:"lunsConfigMap"`
+	ClaimsFile      string        `json:"claimsFile"`
+	ClaimsConfigMap *ConfigMapKey `json:"claimsConfigMap"`
+	// WipeOnDelete zeroes the LUNs before returning them to the pool
+	WipeOnDelete bool `json:"wipeOnDelete"`
+}
+
+// ConfigMapKey is a key of a ConfigMap
+type ConfigMapKey struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+}
+
+// Entry is a LUN of the pool
+type Entry struct {
+	// ID identifies the LUN in the pool, it defaults to <iqn>-<lun>
+	ID      string            `json:"id"`
+	Portals []string          `json:"portals"`
+	IQN     string            `json:"iqn"`
+	LUN     int32             `json:"lun"`
+	Size    resource.Quantity `json:"size"`
+	Labels  map[string]string `json:"labels"`
+	// Topology are the segments the LUN is accessible from, empty for every node
+	Topology []backend.Topology `json:"topology"`
+}
+
+// claim is the claim of a volume on a LUN of the pool
+type claim struct {
+	// Name is the name of the volume
+	Name string `json:"name"`
+	// Released is set when the volume is deleted, until the LUN is returned to the pool
+	Released bool `json:"released,omitempty"`
+	// Wiped is the progress of the wipe of a released LUN with WipeOnDelete
+	Wiped *lunio.Progress `json:"wiped,omitempty"`
+}
+
+// Backend claims LUNs of the pool for volumes
+type Backend struct {
+	config Config
+	luns   document
+	claims document
+
+	// serializes the changes of the claims
+	lock sync.Mutex
+
+	// wipeLUN wipes a LUN from the host, it is replaced in tests
+	wipeLUN   wipeFunc
+	wipesLock sync.Mutex
+	// running and failed wipes, by volume
+	wipes map[string]*wipeJob
+}
+
+var _ backend.Backend = &Backend{}
+
+// New creates a pool backend, the client is only used if the LUNs or the claims are in ConfigMaps
+func New(config Config, client kubernetes.Interface) (*Backend, error) {
+	b := &Backend{config: config, wipeLUN: zeroLUN, wipes: map[string]*wipeJob{}}
+
+	sources := 0
+	if len(config.LUNs) > 0 {
+		sources++
+	}
+	if config.LUNsFile != "" {
+		sources++
+		b.luns = &fileDocument{path: config.LUNsFile}
+	}
+	if config.LUNsConfigMap != nil {
+		sources++
+		b.luns = newConfigMapDocument(client, config.LUNsConfigMap)
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("exactly one of luns, lunsFile and lunsConfigMap is required")
+	}
+
+	switch {
+	case config.ClaimsFile != "" && config.ClaimsConfigMap == nil:
+		b.claims = &fileDocument{path: config.ClaimsFile}
+	case config.ClaimsFile == "" && config.ClaimsConfigMap != nil:
+		b.claims = newConfigMapDocument(client, config.ClaimsConfigMap)
+	default:
+		return nil, fmt.Errorf("exactly one of claimsFile and claimsConfigMap is required")
+	}
+
+	return b, nil
+}
+
+func newConfigMapDocument(client kubernetes.Interface, key *ConfigMapKey) *configMapDocument {
+	return &configMapDocument{client: client, namespace: key.Namespace, name: key.Name, key: key.Key}
+}
+
+func (b *Backend) Capabilities() backend.Capabilities {
+	return backend.Capabilities{
+		Capacity: true,
+		Get:      true,
+		List:     true,
+		Wipe:     b.config.WipeOnDelete,
+	}
+}
+
+func (b *Backend) CreateLUN(ctx context.Context, req *backend.CreateLUNRequest) (*backend.LUN, error) {
+	if req.SourceSnapshot != nil {
+		return nil, fmt.Errorf("restore of pre-provisioned LUNs: %w", backend.ErrNotSupported)
+	}
+	if req.SourceLUNID != "" {
+		return nil, fmt.Errorf("clone of pre-provisioned LUNs: %w", backend.ErrNotSupported)
+	}
+	selector, err := labels.Parse(req.Parameters[SelectorParameter])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter: %v", SelectorParameter, err)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entries, err := b.loadEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, version, err := b.loadClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, c := range claims {
+		if c.Name != req.Name || c.Released {
+			continue
+		}
+		entry, ok := entries[id]
+		if !ok {
+			return nil, fmt.Errorf("LUN %s claimed by %s is no longer in the pool", id, req.Name)
+		}
+		size := entry.Size.Value()
+		if size < req.RequiredBytes || (req.LimitBytes > 0 && size > req.LimitBytes) {
+			return nil, fmt.Errorf("LUN %s of %d bytes: %w", id, size, backend.ErrAlreadyExists)
+		}
+		return entryLUN(entry, c), nil
+	}
+
+	// the free LUN that fits accessible from the first preferred segment, then the smallest one, in
+	// the order of the IDs among the LUNs of the same size
+	var best *Entry
+	var bestPreference int
+	for _, entry := range sortedEntries(entries) {
+		size := entry.Size.Value()
+		if _, claimed := claims[entry.ID]; claimed {
+			continue
+		}
+		if size < req.RequiredBytes || (req.LimitBytes > 0 && size > req.LimitBytes) {
+			continue
+		}
+		if !selector.Matches(labels.Set(entry.Labels)) {
+			continue
+		}
+		topology := entryTopology(entry)
+		if !backend.Accessible(topology, req.Requisite) {
+			continue
+		}
+		preference := backend.Preference(topology, req.Preferred)
+		if best == nil || preference < bestPreference || (preference == bestPreference && size < best.Size.Value()) {
+			best = entry
+			bestPreference = preference
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no free LUN of at least %d bytes matching %q accessible from the requisite topology: %w", req.RequiredBytes, selector.String(), backend.ErrOutOfCapacity)
+	}
+
+	c := claim{Name: req.Name}
+	claims[best.ID] = c
+	if err := b.saveClaims(ctx, claims, version); err != nil {
+		return nil, err
+	}
+
+	klog.V(2).Infof("pool: claimed LUN %s for %s", best.ID, req.Name)
+	return entryLUN(best, c), nil
+}
+
+func (b *Backend) DeleteLUN(ctx context.Context, lunID string) error {
+	entry, c, err := b.releaseClaim(ctx, lunID)
+	if err != nil {
+		return err
+	}
+
+	// the LUN is kept out of the pool by its released claim until it is wiped
+	if entry != nil {
+		if err := b.wipe(lunID, entry, c); err != nil {
+			return err
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	claims, version, err := b.loadClaims(ctx)
+	if err != nil {
+		return err
+	}
+	entryID, _, err := findClaim(claims, lunID)
+	if err != nil {
+		// the claim was deleted meanwhile by a retry
+		return nil
+	}
+	delete(claims, entryID)
+	if err := b.saveClaims(ctx, claims, version); err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("pool: returned LUN %s of %s to the pool", lunID, c.Name)
+	return nil
+}
+
+// releaseClaim marks the claim of a LUN released when it has to be wiped, so that it is not handed out
+// with the data of the deleted volume if the wipe fails. It returns the LUN to wipe, nil if there is
+// none, and the claim.
+func (b *Backend) releaseClaim(ctx context.Context, lunID string) (*Entry, claim, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entries, err := b.loadEntries(ctx)
+	if err != nil {
+		return nil, claim{}, err
+	}
+	claims, version, err := b.loadClaims(ctx)
+	if err != nil {
+		return nil, claim{}, err
+	}
+	entryID, c, err := findClaim(claims, lunID)
+	if err != nil {
+		return nil, claim{}, err
+	}
+	if !b.config.WipeOnDelete {
+		return nil, c, nil
+	}
+
+	entry, ok := entries[entryID]
+	if !ok {
+		klog.Warningf("pool: LUN %s is no longer in the pool, not wiping it", entryID)
+		return nil, c, nil
+	}
+	if !c.Released {
+		c.Released = true
+		claims[entryID] = c
+		if err := b.saveClaims(ctx, claims, version); err != nil {
+			return nil, claim{}, err
+		}
+	}
+	return entry, c, nil
+}
+
+func (b *Backend) GetLUN(ctx context.Context, lunID string) (*backend.LUN, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entries, err := b.loadEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, _, err := b.loadClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entryID, c, err := findClaim(claims, lunID)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[entryID]
+	if c.Released || !ok {
+		return nil, fmt.Errorf("LUN %s: %w", lunID, backend.ErrNotFound)
+	}
+	return entryLUN(entry, c), nil
+}
+
+// ListLUNs returns the claimed LUNs
+func (b *Backend) ListLUNs(ctx context.Context) ([]*backend.LUN, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entries, err := b.loadEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, _, err := b.loadClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	luns := []*backend.LUN{}
+	for id, c := range claims {
+		if entry, ok := entries[id]; ok && !c.Released {
+			luns = append(luns, entryLUN(entry, c))
+		}
+	}
+	return luns, nil
+}
+
+func (b *Backend) ExpandLUN(ctx context.Context, lunID string, capacityBytes int64) (int64, error) {
+	return 0, fmt.Errorf("expansion of pre-provisioned LUNs: %w", backend.ErrNotSupported)
+}
+
+// GrantInitiator returns the target of the LUN, the access of the initiators is managed on the
+// storage system
+func (b *Backend) GrantInitiator(ctx context.Context, lunID string, host backend.Host) (*backend.Access, error) {
+	lun, err := b.GetLUN(ctx, lunID)
+	if err != nil {
+		return nil, err
+	}
+	return &backend.Access{Target: lun.Target}, nil
+}
+
+func (b *Backend) RevokeInitiator(ctx context.Context, lunID string, host backend.Host) error {
+	return nil
+}
+
+func (b *Backend) CreateSnapshot(ctx context.Context, lunID, name string) (*backend.Snapshot, error) {
+	return nil, fmt.Errorf("snapshots of pre-provisioned LUNs: %w", backend.ErrNotSupported)
+}
+
+func (b *Backend) DeleteSnapshot(ctx context.Context, lunID, snapshotID string) error {
+	return fmt.Errorf("snapshots of pre-provisioned LUNs: %w", backend.ErrNotSupported)
+}
+
+func (b *Backend) ListSnapshots(ctx context.Context, lunID string) ([]*backend.Snapshot, error) {
+	return nil, fmt.Errorf("snapshots of pre-provisioned LUNs: %w", backend.ErrNotSupported)
+}
+
+// Capacity returns the size of the largest free LUN matching the selector parameter and accessible
+// from segment, which is the largest volume that can be created
+func (b *Backend) Capacity(ctx context.Context, parameters map[string]string, segment backend.Topology) (int64, error) {
+	selector, err := labels.Parse(parameters[SelectorParameter])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s parameter: %v", SelectorParameter, err)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entries, err := b.loadEntries(ctx)
+	if err != nil {
+		return 0, err
+	}
+	claims, _, err := b.loadClaims(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var largest int64
+	for id, entry := range entries {
+		if _, claimed := claims[id]; claimed || !selector.Matches(labels.Set(entry.Labels)) {
+			continue
+		}
+		if segment != nil && !backend.AccessibleFrom(entryTopology(entry), segment) {
+			continue
+		}
+		largest = max(largest, entry.Size.Value())
+	}
+	return largest, nil
+}
+
+// loadEntries returns the LUNs of the pool by ID
+func (b *Backend) loadEntries(ctx context.Context) (map[string]*Entry, error) {
+	list := b.config.LUNs
+	if b.luns != nil {
+		data, _, err := b.luns.read(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the LUNs of the pool: %v", err)
+		}
+		list = nil
+		if err := yaml.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("failed to parse the LUNs of the pool: %v", err)
+		}
+	}
+
+	entries := map[string]*Entry{}
+	for i := range list {
+		entry := list[i]
+		if entry.IQN == "" || len(entry.Portals) == 0 || entry.Size.IsZero() {
+			return nil, fmt.Errorf("LUN %d of the pool has no iqn, portals or size", i)
+		}
+		if entry.ID == "" {
+			entry.ID = fmt.Sprintf("%s-%d", entry.IQN, entry.LUN)
+		}
+		if _, ok := entries[entry.ID]; ok {
+			return nil, fmt.Errorf("LUN %s is twice in the pool", entry.ID)
+		}
+		entries[entry.ID] = &entry
+	}
+	return entries, nil
+}
+
+// loadClaims returns the claims by LUN ID, and the version of the document they are stored in
+func (b *Backend) loadClaims(ctx context.Context) (map[string]claim, string, error) {
+	data, version, err := b.claims.read(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the claims: %v", err)
+	}
+	claims := map[string]claim{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &claims); err != nil {
+			return nil, "", fmt.Errorf("failed to parse the claims: %v", err)
+		}
+	}
+	return claims, version, nil
+}
+
+func (b *Backend) saveClaims(ctx context.Context, claims map[string]claim, version string) error {
+	data, err := json.MarshalIndent(claims, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := b.claims.write(ctx, data, version); err != nil {
+		return fmt.Errorf("failed to save the claims: %w", err)
+	}
+	return nil
+}
+
+// volumeID returns the ID of the volume claiming a LUN of the pool
+func volumeID(entryID, name string) string {
+	return entryID + volumeIDSeparator + name
+}
+
+// findClaim returns the ID of the LUN of the pool claimed by a volume and its claim, ErrNotFound if the
+// volume does not hold the current claim of the LUN, e.g. a volume deleted before the LUN was claimed
+// again by another volume
+func findClaim(claims map[string]claim, id string) (string, claim, error) {
+	// the names of the volumes never contain the separator, the IDs of the LUNs may
+	i := strings.LastIndex(id, volumeIDSeparator)
+	if i < 0 {
+		return "", claim{}, fmt.Errorf("LUN %s: %w", id, backend.ErrNotFound)
+	}
+	entryID, name := id[:i], id[i+len(volumeIDSeparator):]
+	c, ok := claims[entryID]
+	if !ok || c.Name != name {
+		return "", claim{}, fmt.Errorf("LUN %s: %w", id, backend.ErrNotFound)
+	}
+	return entryID, c, nil
+}
+
+func sortedEntries(entries map[string]*Entry) []*Entry {
+	sorted := []*Entry{}
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+func entryTarget(entry *Entry) backend.Target {
+	return backend.Target{
+		Portals: append([]string{}, entry.Portals...),
+		IQN:     entry.IQN,
+		LUN:     entry.LUN,
+	}
+}
+
+func entryLUN(entry *Entry, c claim) *backend.LUN {
+	return &backend.LUN{
+		ID:            volumeID(entry.ID, c.Name),
+		Name:          c.Name,
+		CapacityBytes: entry.Size.Value(),
+		Target:        entryTarget(entry),
+		Topology:      entryTopology(entry),
+	}
+}
+
+// entryTopology returns the segments a LUN of the pool is accessible from, nil for every node
+func entryTopology(entry *Entry) []backend.Topology {
+	if len(entry.Topology) == 0 {
+		return nil
+	}
+	return entry.Topology
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// testEntries are the LUNs of the pool of the tests
+func testEntries() []Entry {
+	entry := func(id, size string, labels map[string]string) Entry {
+		return Entry{ID: id, Portals: []string{"10.0.0.1:3260"}, IQN: "iqn.2026-01.io.example:array1", Size: resource.MustParse(size), Labels: labels}
+	}
+	return []Entry{
+		entry("lun-large", "10Gi", map[string]string{"tier": "gold"}),
+		entry("lun-small-b", "1Gi", map[string]string{"tier": "silver"}),
+		entry("lun-small-a", "1Gi", map[string]string{"tier": "silver"}),
+		entry("lun-medium", "5Gi", map[string]string{"tier": "gold"}),
+	}
+}
+
+func newTestBackend(t *testing.T) (*Backend, string) {
+	t.Helper()
+	claimsFile := filepath.Join(t.TempDir(), "claims.json")
+	b, err := New(Config{LUNs: testEntries(), ClaimsFile: claimsFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, claimsFile
+}
+
+// entryID returns the ID of the LUN of the pool a volume claims
+func entryID(volumeID string) string {
+	return volumeID[:strings.LastIndex(volumeID, volumeIDSeparator)]
+}
+
+func TestCreateLUN(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      backend.CreateLUNRequest
+		expected string
+		wantErr  error
+	}{
+		{
+			name:     "best fit, in the order of the IDs",
+			req:      backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 1 << 30},
+			expected: "lun-small-a",
+		},
+		{
+			name:     "smallest LUN that fits",
+			req:      backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 2 << 30},
+			expected: "lun-medium",
+		},
+		{
+			name:     "label selector",
+			req:      backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 1 << 30, Parameters: map[string]string{SelectorParameter: "tier=gold"}},
+			expected: "lun-medium",
+		},
+		{
+			name:    "limit",
+			req:     backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 6 << 30, LimitBytes: 8 << 30},
+			wantErr: backend.ErrOutOfCapacity,
+		},
+		{
+			name:    "no LUN matching the selector",
+			req:     backend.CreateLUNRequest{Name: "pvc-1", Parameters: map[string]string{SelectorParameter: "tier=bronze"}},
+			wantErr: backend.ErrOutOfCapacity,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, _ := newTestBackend(t)
+			lun, err := b.CreateLUN(context.Background(), &test.req)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if lun.ID != volumeID(test.expected, test.req.Name) {
+				t.Errorf("expected LUN %s, got %s", test.expected, lun.ID)
+			}
+		})
+	}
+}
+
+func TestCreateLUNIdempotent(t *testing.T) {
+	b, _ := newTestBackend(t)
+	ctx := context.Background()
+	req := &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 1 << 30}
+
+	lun, err := b.CreateLUN(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := b.CreateLUN(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to create LUN again: %v", err)
+	}
+	if again.ID != lun.ID {
+		t.Errorf("expected the LUN claimed by the first attempt %s, got %s", lun.ID, again.ID)
+	}
+	if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 2 << 30}); !errors.Is(err, backend.ErrAlreadyExists) {
+		t.Errorf("expected %v for a larger LUN, got %v", backend.ErrAlreadyExists, err)
+	}
+
+	other, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-2", RequiredBytes: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryID(other.ID) != "lun-small-b" {
+		t.Errorf("expected the other small LUN, got %s", other.ID)
+	}
+	luns, err := b.ListLUNs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(luns) != 2 {
+		t.Errorf("expected 2 claimed LUNs, got %d", len(luns))
+	}
+}
+
+func TestReleasedClaim(t *testing.T) {
+	b, claimsFile := newTestBackend(t)
+	ctx := context.Background()
+	claims := `{"lun-small-a": {"name": "pvc-1", "released": true}, "lun-small-b": {"name": "pvc-2"}}`
+	if err := os.WriteFile(claimsFile, []byte(claims), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.GetLUN(ctx, volumeID("lun-small-a", "pvc-1")); !errors.Is(err, backend.ErrNotFound) {
+		t.Errorf("expected the released LUN to be gone, got %v", err)
+	}
+	luns, err := b.ListLUNs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(luns) != 1 || luns[0].ID != volumeID("lun-small-b", "pvc-2") {
+		t.Errorf("expected only the LUN of pvc-2, got %v", luns)
+	}
+	capacity, err := b.Capacity(ctx, map[string]string{SelectorParameter: "tier=silver"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity != 0 {
+		t.Errorf("expected no capacity left, got %d", capacity)
+	}
+
+	// the volume of the released claim is created again on another LUN
+	lun, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryID(lun.ID) != "lun-medium" {
+		t.Errorf("expected the released LUN to stay out of the pool, got %s", lun.ID)
+	}
+}
+
+// racingDocument is a document changed by another writer between each read and write
+type racingDocument struct {
+	document
+	change func() error
+}
+
+func (d *racingDocument) write(ctx context.Context, data []byte, version string) error {
+	if err := d.change(); err != nil {
+		return err
+	}
+	return d.document.write(ctx, data, version)
+}
+
+func TestClaimsConflict(t *testing.T) {
+	b, claimsFile := newTestBackend(t)
+	ctx := context.Background()
+	concurrent := `{"lun-small-a": {"name": "pvc-2"}}`
+	b.claims = &racingDocument{document: b.claims, change: func() error {
+		return os.WriteFile(claimsFile, []byte(concurrent), 0o600)
+	}}
+
+	if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-1", RequiredBytes: 1 << 30}); !errors.Is(err, errConflict) {
+		t.Fatalf("expected %v, got %v", errConflict, err)
+	}
+	data, err := os.ReadFile(claimsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != concurrent {
+		t.Errorf("expected the concurrent claims to be kept, got %s", data)
+	}
+}
+
+func TestFileDocumentVersion(t *testing.T) {
+	ctx := context.Background()
+	d := &fileDocument{path: filepath.Join(t.TempDir(), "claims.json")}
+
+	if err := d.write(ctx, []byte("{}"), ""); err != nil {
+		t.Fatalf("failed to create the document: %v", err)
+	}
+	if err := d.write(ctx, []byte("{}"), ""); !errors.Is(err, errConflict) {
+		t.Errorf("expected %v when creating the document again, got %v", errConflict, err)
+	}
+
+	_, version, err := d.read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.write(ctx, []byte(`{"lun1": {"name": "pvc-1"}}`), version); err != nil {
+		t.Fatalf("failed to write the document: %v", err)
+	}
+	if err := d.write(ctx, []byte(`{"lun1": {"name": "pvc-2"}}`), version); !errors.Is(err, errConflict) {
+		t.Errorf("expected %v with a stale version, got %v", errConflict, err)
+	}
+	data, _, err := d.read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"lun1": {"name": "pvc-1"}}` {
+		t.Errorf("unexpected content %s", data)
+	}
+}
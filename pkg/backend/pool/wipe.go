@@ -0,0 +1,116 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+	klog "k8s.io/klog/v2"
+)
+
+// wipeFunc wipes a LUN from the offset of progress, passing the progress made to checkpoint
+type wipeFunc func(ctx context.Context, name string, access *backend.Access, progress lunio.Progress, checkpoint func(lunio.Progress) error) error
+
+type wipeJob struct {
+	progress lunio.Progress
+	// closed when the wipe ends, with err set if it failed
+	done chan struct{}
+	err  error
+}
+
+// zeroLUN zeroes a LUN from the host
+func zeroLUN(ctx context.Context, name string, access *backend.Access, progress lunio.Progress, checkpoint func(lunio.Progress) error) error {
+	return lunio.WipeLUN(ctx, name, access, lunio.WipeZero, progress, checkpoint)
+}
+
+// wipe returns nil once the LUN claimed by a deleted volume is wiped, and starts or resumes its wipe
+// otherwise. The wipe runs in the background as it takes as long as writing the whole LUN, which
+// outlasts the DeleteVolume calls, and its progress is saved in the claim, so that it resumes after a
+// failure or a restart of the controller.
+func (b *Backend) wipe(volumeID string, entry *Entry, c claim) error {
+	b.wipesLock.Lock()
+	defer b.wipesLock.Unlock()
+
+	if job, ok := b.wipes[volumeID]; ok {
+		select {
+		case <-job.done:
+			delete(b.wipes, volumeID)
+			if job.err != nil {
+				// the next attempt resumes from the last checkpoint
+				return fmt.Errorf("failed to wipe LUN %s: %v", entry.ID, job.err)
+			}
+			return nil
+		default:
+			return fmt.Errorf("wipe of LUN %s at %d/%d bytes: %w", entry.ID, job.progress.Offset, job.progress.Size, backend.ErrInProgress)
+		}
+	}
+
+	var progress lunio.Progress
+	if c.Wiped != nil {
+		progress = *c.Wiped
+		if progress.Size > 0 && progress.Offset >= progress.Size {
+			return nil
+		}
+	}
+
+	job := &wipeJob{progress: progress, done: make(chan struct{})}
+	b.wipes[volumeID] = job
+	go b.runWipe(job, volumeID, entry)
+
+	return fmt.Errorf("wipe of LUN %s started at offset %d: %w", entry.ID, progress.Offset, backend.ErrInProgress)
+}
+
+func (b *Backend) runWipe(job *wipeJob, volumeID string, entry *Entry) {
+	defer close(job.done)
+
+	access := &backend.Access{Target: entryTarget(entry)}
+	job.err = b.wipeLUN(context.Background(), "pool-"+entry.ID, access, job.progress, func(progress lunio.Progress) error {
+		if err := b.saveWipeProgress(context.Background(), volumeID, progress); err != nil {
+			return err
+		}
+		b.wipesLock.Lock()
+		job.progress = progress
+		b.wipesLock.Unlock()
+		klog.V(4).Infof("pool: wipe of LUN %s: %d/%d bytes", entry.ID, progress.Offset, progress.Size)
+		return nil
+	})
+	if job.err != nil {
+		klog.Errorf("pool: failed to wipe LUN %s: %v", entry.ID, job.err)
+	}
+}
+
+// saveWipeProgress records the progress of the wipe of the LUN claimed by a deleted volume in its
+// claim
+func (b *Backend) saveWipeProgress(ctx context.Context, volumeID string, progress lunio.Progress) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	claims, version, err := b.loadClaims(ctx)
+	if err != nil {
+		return err
+	}
+	entryID, c, err := findClaim(claims, volumeID)
+	if err != nil {
+		return err
+	}
+	c.Wiped = &progress
+	claims[entryID] = c
+	return b.saveClaims(ctx, claims, version)
+}
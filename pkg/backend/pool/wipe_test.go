@@ -0,0 +1,111 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// deleteUntilDone retries the deletion of a LUN while its wipe is in progress
+func deleteUntilDone(t *testing.T, b *Backend, lunID string) error {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if err := b.DeleteLUN(context.Background(), lunID); !errors.Is(err, backend.ErrInProgress) {
+			return err
+		}
+	}
+	t.Fatalf("wipe of LUN %s did not complete", lunID)
+	return nil
+}
+
+func TestDeleteLUNResumesWipe(t *testing.T) {
+	ctx := context.Background()
+	config := Config{
+		LUNs: []Entry{
+			{ID: "lun1", Portals: []string{"10.0.0.1:3260"}, IQN: "iqn.2026-01.io.example:array1", Size: resource.MustParse("1Gi")},
+		},
+		ClaimsFile:   filepath.Join(t.TempDir(), "claims.json"),
+		WipeOnDelete: true,
+	}
+	offsets := make(chan int64, 2)
+
+	b, err := New(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lun, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first wipe fails halfway
+	b.wipeLUN = func(ctx context.Context, name string, access *backend.Access, progress lunio.Progress, checkpoint func(lunio.Progress) error) error {
+		offsets <- progress.Offset
+		if err := checkpoint(lunio.Progress{Offset: 512 << 20, Size: 1 << 30}); err != nil {
+			return err
+		}
+		return errors.New("I/O error")
+	}
+	if err := b.DeleteLUN(ctx, lun.ID); !errors.Is(err, backend.ErrInProgress) {
+		t.Fatalf("expected the wipe to start, got %v", err)
+	}
+	if err := deleteUntilDone(t, b, lun.ID); err == nil {
+		t.Fatalf("expected the failure of the wipe")
+	}
+	if offset := <-offsets; offset != 0 {
+		t.Errorf("expected the wipe to start at offset 0, got %d", offset)
+	}
+	if _, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-2"}); !errors.Is(err, backend.ErrOutOfCapacity) {
+		t.Errorf("expected the released LUN to stay out of the pool, got %v", err)
+	}
+
+	// the wipe resumes from its checkpoint after a restart of the controller
+	b, err = New(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.wipeLUN = func(ctx context.Context, name string, access *backend.Access, progress lunio.Progress, checkpoint func(lunio.Progress) error) error {
+		offsets <- progress.Offset
+		return checkpoint(lunio.Progress{Offset: 1 << 30, Size: 1 << 30})
+	}
+	if err := deleteUntilDone(t, b, lun.ID); err != nil {
+		t.Fatalf("failed to delete LUN: %v", err)
+	}
+	if offset := <-offsets; offset != 512<<20 {
+		t.Errorf("expected the wipe to resume at offset %d, got %d", 512<<20, offset)
+	}
+
+	// the LUN is back in the pool, under another volume ID
+	claimed, err := b.CreateLUN(ctx, &backend.CreateLUNRequest{Name: "pvc-2"})
+	if err != nil {
+		t.Fatalf("failed to claim the wiped LUN: %v", err)
+	}
+	if claimed.ID == lun.ID {
+		t.Errorf("expected a new volume ID, got %s", claimed.ID)
+	}
+	if err := b.DeleteLUN(ctx, lun.ID); !errors.Is(err, backend.ErrNotFound) {
+		t.Errorf("expected the deleted volume to be gone, got %v", err)
+	}
+}
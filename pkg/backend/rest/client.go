@@ -0,0 +1,329 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	klog "k8s.io/klog/v2"
+)
+
+// maxResponseSize bounds the size of the responses that are read
+const maxResponseSize = 1 << 20
+
+// templateData is available to the URL and body templates
+type templateData struct {
+	BaseURL string
+	// Name is the name of the LUN to create, or of the snapshot to take
+	Name          string
+	LUNID         string
+	SnapshotID    string
+	RequiredBytes int64
+	LimitBytes    int64
+	CapacityBytes int64
+	// SourceLUNID and SourceSnapshotID are the snapshot a LUN is restored from, SourceLUNID alone
+	// the LUN a LUN is cloned from
+	SourceLUNID      string
+	SourceSnapshotID string
+	// Parameters are the StorageClass parameters
+	Parameters   map[string]string
+	NodeID       string
+	InitiatorIQN string
+}
+
+var templateFuncs = template.FuncMap{
+	// json quotes a value for a JSON body
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"pathEscape":  url.PathEscape,
+	"queryEscape": url.QueryEscape,
+}
+
+type operation struct {
+	Operation
+	url  *template.Template
+	body *template.Template
+}
+
+func parseOperation(name string, op Operation) (*operation, error) {
+	if op.Method == "" || op.URL == "" {
+		return nil, fmt.Errorf("operation %s has no method or url", name)
+	}
+	parsed := &operation{Operation: op}
+
+	var err error
+	if parsed.url, err = template.New(name + " url").Funcs(templateFuncs).Option("missingkey=error").Parse(op.URL); err != nil {
+		return nil, fmt.Errorf("invalid url template of operation %s: %v", name, err)
+	}
+	if op.Body != "" {
+		if parsed.body, err = template.New(name + " body").Funcs(templateFuncs).Option("missingkey=error").Parse(op.Body); err != nil {
+			return nil, fmt.Errorf("invalid body template of operation %s: %v", name, err)
+		}
+	}
+	return parsed, nil
+}
+
+func newHTTPClient(config TLS, timeout time.Duration) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate in %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// do sends the request of an operation, and returns its decoded JSON response
+func (b *Backend) do(ctx context.Context, name string, data *templateData) (interface{}, error) {
+	op := b.operations[name]
+	if op == nil {
+		return nil, fmt.Errorf("operation %s: %w", name, backend.ErrNotSupported)
+	}
+	data.BaseURL = strings.TrimSuffix(b.config.BaseURL, "/")
+
+	var u bytes.Buffer
+	if err := op.url.Execute(&u, data); err != nil {
+		return nil, fmt.Errorf("failed to render url of operation %s: %v", name, err)
+	}
+	var body io.Reader
+	if op.body != nil {
+		var buf bytes.Buffer
+		if err := op.body.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render body of operation %s: %v", name, err)
+		}
+		body = &buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, op.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range op.Headers {
+		req.Header.Set(k, v)
+	}
+	if err := b.authenticate(req); err != nil {
+		return nil, err
+	}
+
+	klog.V(4).Infof("rest: %s %s", op.Method, req.URL.Redacted())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("operation %s failed: %v", name, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response of operation %s: %v", name, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("operation %s failed with status %d: %s", name, resp.StatusCode, bytes.TrimSpace(respBody))
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("%v: %w", err, backend.ErrNotFound)
+		case http.StatusConflict:
+			return nil, fmt.Errorf("%v: %w", err, backend.ErrAlreadyExists)
+		case http.StatusInsufficientStorage:
+			return nil, fmt.Errorf("%v: %w", err, backend.ErrOutOfCapacity)
+		}
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(respBody)) == 0 {
+		return nil, nil
+	}
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode response of operation %s: %v", name, err)
+	}
+	return decoded, nil
+}
+
+// authenticate adds the credentials to a request, the files are read for every request so that
+// rotated credentials are picked up
+func (b *Backend) authenticate(req *http.Request) error {
+	auth := b.config.Auth
+	switch auth.Type {
+	case "":
+		return nil
+	case "basic":
+		password, err := secret(auth.Password, auth.PasswordFile)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(auth.Username, password)
+	case "bearer":
+		token, err := secret(auth.Token, auth.TokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "header":
+		token, err := secret(auth.Token, auth.TokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(auth.Header, token)
+	default:
+		return fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+	return nil
+}
+
+func secret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// field returns a field of the response of an operation
+func (b *Backend) field(op string, resp interface{}, field string) (interface{}, bool) {
+	path, ok := b.operations[op].Response[field]
+	if !ok {
+		return nil, false
+	}
+	value, ok := lookup(resp, path)
+	if !ok || value == nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (b *Backend) stringField(op string, resp interface{}, field string) (string, bool) {
+	value, ok := b.field(op, resp, field)
+	if !ok {
+		return "", false
+	}
+	return toString(value), true
+}
+
+func (b *Backend) int64Field(op string, resp interface{}, field string) (int64, bool, error) {
+	value, ok := b.stringField(op, resp, field)
+	if !ok {
+		return 0, false, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s %q in response of %s: %v", field, value, op, err)
+	}
+	return i, true, nil
+}
+
+// lookup returns the value at a dot-separated path in a decoded JSON document, the elements of the
+// arrays are addressed by their index
+func lookup(document interface{}, path string) (interface{}, bool) {
+	value := document
+	if path == "" {
+		return value, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// toStrings converts an array, or a comma-separated string, to strings
+func toStrings(value interface{}) []string {
+	if values, ok := value.([]interface{}); ok {
+		strs := []string{}
+		for _, v := range values {
+			strs = append(strs, toString(v))
+		}
+		return strs
+	}
+
+	strs := []string{}
+	for _, s := range strings.Split(toString(value), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,455 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest implements a backend for storage systems with a REST API. Each operation of the
+// backend is an HTTP request, described by a method and templates of its URL and JSON body, and the
+// fields of the LUNs and snapshots are extracted from the JSON responses.
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Name is the name the backend is registered with
+const Name = "rest"
+
+// the operations of the backend
+const (
+	OpCreate         = "create"
+	OpClone          = "clone"
+	OpDelete         = "delete"
+	OpGet            = "get"
+	OpMap            = "map"
+	OpUnmap          = "unmap"
+	OpExpand         = "expand"
+	OpSnapshot       = "snapshot"
+	OpDeleteSnapshot = "deleteSnapshot"
+	OpCapacity       = "capacity"
+)
+
+// the fields extracted from the responses
+const (
+	FieldID             = "id"
+	FieldPortals        = "portals"
+	FieldIQN            = "iqn"
+	FieldLUN            = "lun"
+	FieldCapacityBytes  = "capacityBytes"
+	FieldCHAPUser       = "chapUser"
+	FieldCHAPPassword   = "chapPassword"
+	FieldSnapshotID     = "snapshotID"
+	FieldSizeBytes      = "sizeBytes"
+	FieldReadyToUse     = "readyToUse"
+	FieldAvailableBytes = "availableBytes"
+	FieldHealth         = "health"
+	FieldHealthMessage  = "healthMessage"
+)
+
+// healthStatuses are the values of the health field of the unhealthy LUNs
+var healthStatuses = map[string]backend.HealthStatus{
+	"degraded":     backend.HealthDegraded,
+	"inaccessible": backend.HealthInaccessible,
+	"dataLoss":     backend.HealthDataLoss,
+}
+
+const defaultTimeout = 30 * time.Second
+
+func init() {
+	backend.Register(Name, func(config []byte) (backend.Backend, error) {
+		cfg := Config{}
+		if err := yaml.UnmarshalStrict(config, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+		return New(cfg)
+	})
+}
+
+// Config is the configuration of the REST backend
+type Config struct {
+	// BaseURL is available to the templates as .BaseURL
+	BaseURL string `json:"baseURL"`
+	// Timeout of the requests, 30s by default
+	Timeout *metav1.Duration `json:"timeout"`
+	Auth    Auth             `json:"auth"`
+	TLS     TLS              `json:"tls"`
+	// Target is the default target of the LUNs, for the fields missing from the responses
+	Target TargetDefaults `json:"target"`
+	// Operations by name, create and delete are required
+	Operations map[string]Operation `json:"operations"`
+	// Topology are the segments the LUNs are accessible from, empty for every node
+	Topology []backend.Topology `json:"topology"`
+}
+
+// Auth is how the requests are authenticated
+type Auth struct {
+	// Type is basic, bearer, header, or empty for no authentication
+	Type         string `json:"type"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"passwordFile"`
+	Token        string `json:"token"`
+	TokenFile    string `json:"tokenFile"`
+	// Header is the name of the header holding the token with the header type
+	Header string `json:"header"`
+}
+
+// TLS is how the server is authenticated, and how the backend authenticates to it
+type TLS struct {
+	CAFile             string `json:"caFile"`
+	CertFile           string `json:"certFile"`
+	KeyFile            string `json:"keyFile"`
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+}
+
+// TargetDefaults is the default target of the LUNs
+type TargetDefaults struct {
+	Portals []string `json:"portals"`
+	IQN     string   `json:"iqn"`
+}
+
+// Operation is the HTTP request of an operation
+type Operation struct {
+	Method string `json:"method"`
+	// URL and Body are text/template templates
+	URL     string            `json:"url"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+	// Response maps fields to their dot-separated path in the JSON response, e.g. data.lun.id
+	Response map[string]string `json:"response"`
+	// Context maps volume context keys to their path in the JSON response of create and get
+	Context map[string]string `json:"context"`
+}
+
+// Backend provisions LUNs through the REST API of a storage system
+type Backend struct {
+	config     Config
+	client     *http.Client
+	operations map[string]*operation
+}
+
+var _ backend.Backend = &Backend{}
+
+// New creates a REST backend
+func New(config Config) (*Backend, error) {
+	for _, op := range []string{OpCreate, OpDelete} {
+		if _, ok := config.Operations[op]; !ok {
+			return nil, fmt.Errorf("operation %s is required", op)
+		}
+	}
+	if _, ok := config.Operations[OpMap]; ok != hasOperation(config, OpUnmap) {
+		return nil, fmt.Errorf("operations %s and %s go together", OpMap, OpUnmap)
+	}
+
+	operations := map[string]*operation{}
+	for name, op := range config.Operations {
+		parsed, err := parseOperation(name, op)
+		if err != nil {
+			return nil, err
+		}
+		operations[name] = parsed
+	}
+
+	timeout := defaultTimeout
+	if config.Timeout != nil {
+		timeout = config.Timeout.Duration
+	}
+	client, err := newHTTPClient(config.TLS, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{config: config, client: client, operations: operations}, nil
+}
+
+func hasOperation(config Config, name string) bool {
+	_, ok := config.Operations[name]
+	return ok
+}
+
+func (b *Backend) Capabilities() backend.Capabilities {
+	return backend.Capabilities{
+		Expand:   b.operations[OpExpand] != nil,
+		Snapshot: b.operations[OpSnapshot] != nil && b.operations[OpDeleteSnapshot] != nil,
+		Clone:    b.operations[OpClone] != nil,
+		Capacity: b.operations[OpCapacity] != nil,
+		ACL:      b.operations[OpMap] != nil,
+		Get:      b.operations[OpGet] != nil,
+	}
+}
+
+func (b *Backend) CreateLUN(ctx context.Context, req *backend.CreateLUNRequest) (*backend.LUN, error) {
+	if !backend.Accessible(b.topology(), req.Requisite) {
+		return nil, fmt.Errorf("LUN %s: %w", req.Name, backend.ErrNotAccessible)
+	}
+	data := &templateData{
+		Name:          req.Name,
+		RequiredBytes: req.RequiredBytes,
+		LimitBytes:    req.LimitBytes,
+		CapacityBytes: req.RequiredBytes,
+		Parameters:    req.Parameters,
+	}
+	if req.SourceSnapshot != nil {
+		data.SourceLUNID = req.SourceSnapshot.LUNID
+		data.SourceSnapshotID = req.SourceSnapshot.SnapshotID
+	}
+	op := OpCreate
+	if req.SourceLUNID != "" {
+		if b.operations[OpClone] == nil {
+			return nil, fmt.Errorf("operation %s: %w", OpClone, backend.ErrNotSupported)
+		}
+		op = OpClone
+		data.SourceLUNID = req.SourceLUNID
+	}
+	resp, err := b.do(ctx, op, data)
+	if errors.Is(err, backend.ErrAlreadyExists) && b.operations[OpGet] != nil {
+		// the LUN may have been created by a previous attempt, whose response was lost
+		lun, getErr := b.GetLUN(ctx, req.Name)
+		if getErr != nil {
+			return nil, err
+		}
+		if lun.CapacityBytes < req.RequiredBytes || (req.LimitBytes > 0 && lun.CapacityBytes > req.LimitBytes) {
+			return nil, err
+		}
+		return lun, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lun, err := b.lun(op, resp, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if lun.CapacityBytes == 0 {
+		lun.CapacityBytes = req.RequiredBytes
+	}
+	klog.V(2).Infof("rest: created LUN %s of %d bytes", lun.ID, lun.CapacityBytes)
+	return lun, nil
+}
+
+func (b *Backend) DeleteLUN(ctx context.Context, lunID string) error {
+	if _, err := b.do(ctx, OpDelete, &templateData{LUNID: lunID}); err != nil {
+		return err
+	}
+	klog.V(2).Infof("rest: deleted LUN %s", lunID)
+	return nil
+}
+
+func (b *Backend) GetLUN(ctx context.Context, lunID string) (*backend.LUN, error) {
+	resp, err := b.do(ctx, OpGet, &templateData{LUNID: lunID, Name: lunID})
+	if err != nil {
+		return nil, err
+	}
+	lun, err := b.lun(OpGet, resp, lunID)
+	if err != nil {
+		return nil, err
+	}
+	lun.ID = lunID
+	return lun, nil
+}
+
+func (b *Backend) ListLUNs(ctx context.Context) ([]*backend.LUN, error) {
+	return nil, fmt.Errorf("listing LUNs: %w", backend.ErrNotSupported)
+}
+
+func (b *Backend) ExpandLUN(ctx context.Context, lunID string, capacityBytes int64) (int64, error) {
+	resp, err := b.do(ctx, OpExpand, &templateData{LUNID: lunID, CapacityBytes: capacityBytes, RequiredBytes: capacityBytes})
+	if err != nil {
+		return 0, err
+	}
+	if capacity, ok, err := b.int64Field(OpExpand, resp, FieldCapacityBytes); err != nil {
+		return 0, err
+	} else if ok {
+		return capacity, nil
+	}
+	return capacityBytes, nil
+}
+
+func (b *Backend) GrantInitiator(ctx context.Context, lunID string, host backend.Host) (*backend.Access, error) {
+	resp, err := b.do(ctx, OpMap, &templateData{LUNID: lunID, NodeID: host.NodeID, InitiatorIQN: host.InitiatorIQN})
+	if err != nil {
+		return nil, err
+	}
+
+	access := &backend.Access{}
+	if b.hasTargetFields(OpMap) {
+		if access.Target, err = b.target(OpMap, resp); err != nil {
+			return nil, err
+		}
+	} else {
+		lun, err := b.GetLUN(ctx, lunID)
+		if err != nil {
+			return nil, err
+		}
+		access.Target = lun.Target
+	}
+
+	user, _ := b.stringField(OpMap, resp, FieldCHAPUser)
+	password, _ := b.stringField(OpMap, resp, FieldCHAPPassword)
+	if user != "" && password != "" {
+		access.CHAP = &backend.CHAP{User: user, Password: password}
+	}
+	return access, nil
+}
+
+func (b *Backend) RevokeInitiator(ctx context.Context, lunID string, host backend.Host) error {
+	_, err := b.do(ctx, OpUnmap, &templateData{LUNID: lunID, NodeID: host.NodeID, InitiatorIQN: host.InitiatorIQN})
+	if errors.Is(err, backend.ErrNotFound) {
+		// the mapping is already gone
+		return nil
+	}
+	return err
+}
+
+func (b *Backend) CreateSnapshot(ctx context.Context, lunID, name string) (*backend.Snapshot, error) {
+	resp, err := b.do(ctx, OpSnapshot, &templateData{LUNID: lunID, Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &backend.Snapshot{
+		ID:           name,
+		Name:         name,
+		SourceLUNID:  lunID,
+		CreationTime: time.Now(),
+		ReadyToUse:   true,
+	}
+	if id, ok := b.stringField(OpSnapshot, resp, FieldSnapshotID); ok {
+		snapshot.ID = id
+	}
+	if size, ok, err := b.int64Field(OpSnapshot, resp, FieldSizeBytes); err != nil {
+		return nil, err
+	} else if ok {
+		snapshot.SizeBytes = size
+	}
+	if ready, ok := b.stringField(OpSnapshot, resp, FieldReadyToUse); ok {
+		snapshot.ReadyToUse = ready == "true"
+	}
+	return snapshot, nil
+}
+
+func (b *Backend) DeleteSnapshot(ctx context.Context, lunID, snapshotID string) error {
+	_, err := b.do(ctx, OpDeleteSnapshot, &templateData{LUNID: lunID, SnapshotID: snapshotID})
+	return err
+}
+
+func (b *Backend) ListSnapshots(ctx context.Context, lunID string) ([]*backend.Snapshot, error) {
+	return nil, fmt.Errorf("listing snapshots: %w", backend.ErrNotSupported)
+}
+
+func (b *Backend) Capacity(ctx context.Context, parameters map[string]string, segment backend.Topology) (int64, error) {
+	if segment != nil && !backend.AccessibleFrom(b.topology(), segment) {
+		return 0, nil
+	}
+	resp, err := b.do(ctx, OpCapacity, &templateData{Parameters: parameters})
+	if err != nil {
+		return 0, err
+	}
+	available, ok, err := b.int64Field(OpCapacity, resp, FieldAvailableBytes)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("response of %s has no %s", OpCapacity, FieldAvailableBytes)
+	}
+	return available, nil
+}
+
+// topology returns the segments the LUNs are accessible from, nil for every node
+func (b *Backend) topology() []backend.Topology {
+	if len(b.config.Topology) == 0 {
+		return nil
+	}
+	return b.config.Topology
+}
+
+// lun returns the LUN described by the response of an operation
+func (b *Backend) lun(op string, resp interface{}, name string) (*backend.LUN, error) {
+	target, err := b.target(op, resp)
+	if err != nil {
+		return nil, err
+	}
+	lun := &backend.LUN{ID: name, Name: name, Target: target, Topology: b.topology()}
+	if id, ok := b.stringField(op, resp, FieldID); ok {
+		lun.ID = id
+	}
+	if capacity, ok, err := b.int64Field(op, resp, FieldCapacityBytes); err != nil {
+		return nil, err
+	} else if ok {
+		lun.CapacityBytes = capacity
+	}
+
+	if health, ok := b.stringField(op, resp, FieldHealth); ok && health != "" && health != "healthy" {
+		status, ok := healthStatuses[health]
+		if !ok {
+			return nil, fmt.Errorf("invalid health %q of LUN %s", health, lun.ID)
+		}
+		message, _ := b.stringField(op, resp, FieldHealthMessage)
+		lun.Health = []backend.HealthCondition{{Status: status, Reason: "StorageSystem", Message: message}}
+	}
+
+	for key, path := range b.operations[op].Context {
+		if value, ok := lookup(resp, path); ok {
+			if lun.Context == nil {
+				lun.Context = map[string]string{}
+			}
+			lun.Context[key] = toString(value)
+		}
+	}
+	return lun, nil
+}
+
+// target returns the target described by the response of an operation, completed with the default
+// target
+func (b *Backend) target(op string, resp interface{}) (backend.Target, error) {
+	target := backend.Target{
+		Portals: append([]string{}, b.config.Target.Portals...),
+		IQN:     b.config.Target.IQN,
+	}
+	if value, ok := b.field(op, resp, FieldPortals); ok {
+		target.Portals = toStrings(value)
+	}
+	if iqn, ok := b.stringField(op, resp, FieldIQN); ok {
+		target.IQN = iqn
+	}
+	lun, ok, err := b.int64Field(op, resp, FieldLUN)
+	if err != nil {
+		return target, err
+	}
+	if ok {
+		target.LUN = int32(lun)
+	}
+
+	if len(target.Portals) == 0 || target.IQN == "" {
+		return target, fmt.Errorf("response of %s has no %s or %s, and there is no default target", op, FieldPortals, FieldIQN)
+	}
+	return target, nil
+}
+
+func (b *Backend) hasTargetFields(op string) bool {
+	response := b.operations[op].Response
+	_, hasLUN := response[FieldLUN]
+	return hasLUN
+}
@@ -0,0 +1,337 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+)
+
+// request is a request received by the test server
+type request struct {
+	method string
+	path   string
+	body   string
+	header http.Header
+}
+
+// newTestBackend returns a backend whose storage system is a test server answering the requests with
+// respond, and the requests the server received
+func newTestBackend(t *testing.T, auth Auth, respond func(r request) (int, string)) (*Backend, *[]request) {
+	t.Helper()
+	requests := &[]request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request: %v", err)
+		}
+		req := request{method: r.Method, path: r.URL.EscapedPath(), body: string(body), header: r.Header}
+		*requests = append(*requests, req)
+		code, resp := respond(req)
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, resp)
+	}))
+	t.Cleanup(server.Close)
+
+	lun := map[string]string{
+		FieldID:            "lun.id",
+		FieldLUN:           "lun.number",
+		FieldCapacityBytes: "lun.size",
+	}
+	b, err := New(Config{
+		BaseURL: server.URL + "/api/",
+		Auth:    auth,
+		Target:  TargetDefaults{Portals: []string{"10.0.0.1:3260"}, IQN: "iqn.2026-01.io.example:array1"},
+		Operations: map[string]Operation{
+			OpCreate: {
+				Method:   http.MethodPost,
+				URL:      "{{.BaseURL}}/luns",
+				Body:     `{"name": {{json .Name}}, "size": {{.RequiredBytes}}, "pool": {{json (index .Parameters "pool")}}}`,
+				Response: lun,
+				Context:  map[string]string{"pool": "lun.pool"},
+			},
+			OpGet:    {Method: http.MethodGet, URL: "{{.BaseURL}}/luns/{{pathEscape .LUNID}}", Response: lun},
+			OpDelete: {Method: http.MethodDelete, URL: "{{.BaseURL}}/luns/{{pathEscape .LUNID}}"},
+			OpExpand: {
+				Method:   http.MethodPatch,
+				URL:      "{{.BaseURL}}/luns/{{pathEscape .LUNID}}",
+				Body:     `{"size": {{.CapacityBytes}}}`,
+				Response: lun,
+			},
+			OpSnapshot: {
+				Method: http.MethodPost,
+				URL:    "{{.BaseURL}}/luns/{{pathEscape .LUNID}}/snapshots",
+				Body:   `{"name": {{json .Name}}}`,
+				Response: map[string]string{
+					FieldSnapshotID: "snapshot.id",
+					FieldSizeBytes:  "snapshot.size",
+					FieldReadyToUse: "snapshot.ready",
+				},
+			},
+			OpDeleteSnapshot: {Method: http.MethodDelete, URL: "{{.BaseURL}}/luns/{{pathEscape .LUNID}}/snapshots/{{pathEscape .SnapshotID}}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, requests
+}
+
+func TestCreateLUN(t *testing.T) {
+	const created = `{"lun": {"id": "42", "number": 3, "size": 2147483648, "pool": "gold"}}`
+	tests := []struct {
+		name     string
+		respond  func(r request) (int, string)
+		wantErr  error
+		wantLUN  *backend.LUN
+		requests []string
+	}{
+		{
+			name:    "new LUN",
+			respond: func(r request) (int, string) { return http.StatusCreated, created },
+			wantLUN: &backend.LUN{
+				ID:            "42",
+				Name:          "pvc-1",
+				CapacityBytes: 2 << 30,
+				Target:        backend.Target{Portals: []string{"10.0.0.1:3260"}, IQN: "iqn.2026-01.io.example:array1", LUN: 3},
+				Context:       map[string]string{"pool": "gold"},
+			},
+			requests: []string{"POST /api/luns"},
+		},
+		{
+			name: "LUN created by a previous attempt",
+			respond: func(r request) (int, string) {
+				if r.method == http.MethodPost {
+					return http.StatusConflict, `{"error": "exists"}`
+				}
+				return http.StatusOK, created
+			},
+			wantLUN: &backend.LUN{
+				ID:            "pvc-1",
+				Name:          "pvc-1",
+				CapacityBytes: 2 << 30,
+				Target:        backend.Target{Portals: []string{"10.0.0.1:3260"}, IQN: "iqn.2026-01.io.example:array1", LUN: 3},
+			},
+			requests: []string{"POST /api/luns", "GET /api/luns/pvc-1"},
+		},
+		{
+			name: "LUN of another size created by a previous attempt",
+			respond: func(r request) (int, string) {
+				if r.method == http.MethodPost {
+					return http.StatusConflict, `{"error": "exists"}`
+				}
+				return http.StatusOK, `{"lun": {"id": "42", "number": 3, "size": 1073741824}}`
+			},
+			wantErr:  backend.ErrAlreadyExists,
+			requests: []string{"POST /api/luns", "GET /api/luns/pvc-1"},
+		},
+		{
+			name:     "out of capacity",
+			respond:  func(r request) (int, string) { return http.StatusInsufficientStorage, `{"error": "full"}` },
+			wantErr:  backend.ErrOutOfCapacity,
+			requests: []string{"POST /api/luns"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, requests := newTestBackend(t, Auth{}, test.respond)
+			lun, err := b.CreateLUN(context.Background(), &backend.CreateLUNRequest{
+				Name:          "pvc-1",
+				RequiredBytes: 2 << 30,
+				Parameters:    map[string]string{"pool": "gold"},
+			})
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("expected %v, got %v", test.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if !reflect.DeepEqual(lun, test.wantLUN) {
+				t.Errorf("expected LUN %+v, got %+v", test.wantLUN, lun)
+			}
+
+			sent := []string{}
+			for _, r := range *requests {
+				sent = append(sent, r.method+" "+r.path)
+			}
+			if !reflect.DeepEqual(sent, test.requests) {
+				t.Errorf("expected requests %v, got %v", test.requests, sent)
+			}
+			if body := (*requests)[0].body; body != `{"name": "pvc-1", "size": 2147483648, "pool": "gold"}` {
+				t.Errorf("unexpected body %s", body)
+			}
+			if contentType := (*requests)[0].header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("unexpected content type %q", contentType)
+			}
+		})
+	}
+}
+
+func TestDeleteLUN(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{name: "deleted", status: http.StatusNoContent},
+		{name: "not found", status: http.StatusNotFound, wantErr: backend.ErrNotFound},
+		{name: "conflict", status: http.StatusConflict, wantErr: backend.ErrAlreadyExists},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, requests := newTestBackend(t, Auth{}, func(r request) (int, string) { return test.status, "" })
+			err := b.DeleteLUN(context.Background(), "lun/42")
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("expected %v, got %v", test.wantErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if r := (*requests)[0]; r.method != http.MethodDelete || r.path != "/api/luns/lun%2F42" {
+				t.Errorf("unexpected request %s %s", r.method, r.path)
+			}
+		})
+	}
+
+	b, _ := newTestBackend(t, Auth{}, func(r request) (int, string) { return http.StatusInternalServerError, "boom" })
+	err := b.DeleteLUN(context.Background(), "42")
+	if err == nil || errors.Is(err, backend.ErrNotFound) {
+		t.Errorf("expected a plain error, got %v", err)
+	}
+}
+
+func TestExpandLUN(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected int64
+	}{
+		{name: "capacity in response", response: `{"lun": {"size": 4294967296}}`, expected: 4 << 30},
+		{name: "empty response", expected: 3 << 30},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, requests := newTestBackend(t, Auth{}, func(r request) (int, string) { return http.StatusOK, test.response })
+			capacity, err := b.ExpandLUN(context.Background(), "42", 3<<30)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if capacity != test.expected {
+				t.Errorf("expected capacity %d, got %d", test.expected, capacity)
+			}
+			if r := (*requests)[0]; r.method != http.MethodPatch || r.path != "/api/luns/42" || r.body != `{"size": 3221225472}` {
+				t.Errorf("unexpected request %s %s %s", r.method, r.path, r.body)
+			}
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	b, requests := newTestBackend(t, Auth{}, func(r request) (int, string) {
+		if r.method == http.MethodPost {
+			return http.StatusAccepted, `{"snapshot": {"id": "s7", "size": 1073741824, "ready": false}}`
+		}
+		return http.StatusNoContent, ""
+	})
+
+	snapshot, err := b.CreateSnapshot(context.Background(), "42", "snap-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshot.ID != "s7" || snapshot.Name != "snap-1" || snapshot.SourceLUNID != "42" || snapshot.SizeBytes != 1<<30 || snapshot.ReadyToUse {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	if err := b.DeleteSnapshot(context.Background(), "42", snapshot.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []request{
+		{method: http.MethodPost, path: "/api/luns/42/snapshots", body: `{"name": "snap-1"}`},
+		{method: http.MethodDelete, path: "/api/luns/42/snapshots/s7"},
+	}
+	for i, r := range *requests {
+		if r.method != expected[i].method || r.path != expected[i].path || r.body != expected[i].body {
+			t.Errorf("expected request %+v, got %s %s %s", expected[i], r.method, r.path, r.body)
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("t0ken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		auth   Auth
+		header string
+		value  string
+	}{
+		{
+			name:   "basic",
+			auth:   Auth{Type: "basic", Username: "admin", Password: "secret"},
+			header: "Authorization",
+			value:  "Basic YWRtaW46c2VjcmV0",
+		},
+		{
+			name:   "bearer from file",
+			auth:   Auth{Type: "bearer", TokenFile: tokenFile},
+			header: "Authorization",
+			value:  "Bearer t0ken",
+		},
+		{
+			name:   "header",
+			auth:   Auth{Type: "header", Header: "X-Auth-Token", Token: "t0ken"},
+			header: "X-Auth-Token",
+			value:  "t0ken",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, requests := newTestBackend(t, test.auth, func(r request) (int, string) { return http.StatusNoContent, "" })
+			if err := b.DeleteLUN(context.Background(), "42"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value := (*requests)[0].header.Get(test.header); value != test.value {
+				t.Errorf("expected %s header %q, got %q", test.header, test.value, value)
+			}
+		})
+	}
+
+	// the token file is read again for every request
+	b, requests := newTestBackend(t, Auth{Type: "bearer", TokenFile: tokenFile}, func(r request) (int, string) { return http.StatusNoContent, "" })
+	if err := os.WriteFile(tokenFile, []byte("rotated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeleteLUN(context.Background(), "42"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value := (*requests)[0].header.Get("Authorization"); value != "Bearer rotated" {
+		t.Errorf("expected the rotated token, got %q", value)
+	}
+}
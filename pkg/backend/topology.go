@@ -0,0 +1,72 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+// Topology is a topology segment, the labels identifying the nodes of a region of the cluster,
+// e.g. the racks that reach the portals of a storage system
+type Topology map[string]string
+
+// Within reports whether the nodes in segment are in t, that is whether every label of t is also
+// a label of segment
+func (t Topology) Within(segment Topology) bool {
+	for key, value := range t {
+		if v, ok := segment[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Accessible reports whether a LUN accessible from the given topologies is accessible from one of
+// the requisite segments. A nil accessible LUN is accessible from every node, and an empty
+// requisite accepts any LUN.
+func Accessible(accessible, requisite []Topology) bool {
+	if accessible == nil || len(requisite) == 0 {
+		return true
+	}
+	for _, segment := range requisite {
+		if AccessibleFrom(accessible, segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessibleFrom reports whether a LUN accessible from the given topologies is accessible from the
+// nodes in segment
+func AccessibleFrom(accessible []Topology, segment Topology) bool {
+	if accessible == nil {
+		return true
+	}
+	for _, t := range accessible {
+		if t.Within(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Preference returns the index of the first preferred segment a LUN accessible from the given
+// topologies is accessible from, or len(preferred) if there is none
+func Preference(accessible, preferred []Topology) int {
+	for i, segment := range preferred {
+		if AccessibleFrom(accessible, segment) {
+			return i
+		}
+	}
+	return len(preferred)
+}
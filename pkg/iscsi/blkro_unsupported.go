@@ -1,5 +1,7 @@
+//go:build !linux
+
 /*
-Copyright 2014 The Kubernetes Authors.
+Copyright 2026 The Kubernetes Authors.
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -14,5 +16,10 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Package mount defines an interface to mounting filesystems.
-package mount // import "k8s.io/utils/mount"
+package iscsi
+
+import "fmt"
+
+func setBlockDeviceReadOnly(device string, readOnly bool) error {
+	return fmt.Errorf("read-only flag of %s is not supported on this platform", device)
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	klog "k8s.io/klog/v2"
+)
+
+// hostCloner clones the LUNs of the backends that cannot clone them, by copying their content
+// through the host of the controller. A copy runs in the background, as it outlasts the CreateVolume
+// calls, which return Aborted until it is complete. Its progress is checkpointed in a file per
+// volume, so that it resumes after a failure or a restart of the controller.
+type hostCloner struct {
+	driver *driver
+
+	lock sync.Mutex
+	// running and failed copies, by destination volume
+	jobs map[string]*cloneJob
+}
+
+type cloneJob struct {
+	sourceID string
+	progress lunio.Progress
+	cancel   context.CancelFunc
+	// closed when the copy ends, with err set if it failed
+	done chan struct{}
+	err  error
+}
+
+// cloneCheckpoint is the persisted progress of a copy
+type cloneCheckpoint struct {
+	SourceID string `json:"sourceID"`
+	lunio.Progress
+	Done bool `json:"done"`
+}
+
+func newHostCloner(d *driver) *hostCloner {
+	return &hostCloner{driver: d, jobs: map[string]*cloneJob{}}
+}
+
+// clone returns nil once the content of the source LUN is copied to the destination LUN, and
+// starts or resumes the copy otherwise
+func (c *hostCloner) clone(sourceID string, source, destination *backend.LUN) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if job, ok := c.jobs[destination.ID]; ok {
+		select {
+		case <-job.done:
+			delete(c.jobs, destination.ID)
+			if job.err != nil {
+				// the next attempt resumes from the last checkpoint
+				return status.Errorf(codes.Internal, "failed to copy volume %s to %s: %v", job.sourceID, destination.ID, job.err)
+			}
+			return nil
+		default:
+			return status.Errorf(codes.Aborted, "copy of volume %s to %s in progress: %d/%d bytes", job.sourceID, destination.ID, job.progress.Offset, job.progress.Size)
+		}
+	}
+
+	checkpoint, err := c.readCheckpoint(destination.ID)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if checkpoint == nil || checkpoint.SourceID != sourceID || checkpoint.Size != source.CapacityBytes {
+		checkpoint = &cloneCheckpoint{SourceID: sourceID, Progress: lunio.Progress{Size: source.CapacityBytes}}
+	}
+	if checkpoint.Done {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &cloneJob{sourceID: sourceID, progress: checkpoint.Progress, cancel: cancel, done: make(chan struct{})}
+	c.jobs[destination.ID] = job
+	go c.run(ctx, job, source, destination)
+
+	return status.Errorf(codes.Aborted, "copy of volume %s to %s started at offset %d", sourceID, destination.ID, checkpoint.Offset)
+}
+
+// cancel stops the copy to a volume that is deleted and removes its checkpoint
+func (c *hostCloner) cancel(volumeID string) error {
+	c.lock.Lock()
+	job, ok := c.jobs[volumeID]
+	delete(c.jobs, volumeID)
+	c.lock.Unlock()
+
+	if ok {
+		job.cancel()
+		<-job.done
+	}
+	if c.driver.cloneCheckpointDir == "" {
+		return nil
+	}
+	if err := os.Remove(c.checkpointPath(volumeID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *hostCloner) run(ctx context.Context, job *cloneJob, source, destination *backend.LUN) {
+	defer close(job.done)
+	defer job.cancel()
+	defer cloneProgress.DeleteLabelValues(destination.ID)
+
+	job.err = c.copy(ctx, job, source, destination)
+	if job.err != nil {
+		klog.Errorf("failed to copy volume %s to %s: %v", job.sourceID, destination.ID, job.err)
+		return
+	}
+	job.err = c.writeCheckpoint(destination.ID, &cloneCheckpoint{SourceID: job.sourceID, Progress: job.progress, Done: true})
+}
+
+func (c *hostCloner) copy(ctx context.Context, job *cloneJob, source, destination *backend.LUN) (err error) {
+	sourceAccess, err := c.driver.grantHostAccess(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, c.driver.revokeHostAccess(source)) }()
+	destinationAccess, err := c.driver.grantHostAccess(ctx, destination)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, c.driver.revokeHostAccess(destination)) }()
+
+	name := fmt.Sprintf("clone-%s", destination.ID)
+	return lunio.CopyLUN(ctx, name, sourceAccess, destinationAccess, job.progress.Size, job.progress.Offset, func(progress lunio.Progress) error {
+		if err := c.writeCheckpoint(destination.ID, &cloneCheckpoint{SourceID: job.sourceID, Progress: progress}); err != nil {
+			return err
+		}
+		c.lock.Lock()
+		job.progress = progress
+		c.lock.Unlock()
+		cloneProgress.WithLabelValues(destination.ID).Set(float64(progress.Offset) / float64(progress.Size))
+		klog.V(4).Infof("copy of volume %s to %s: %d/%d bytes", job.sourceID, destination.ID, progress.Offset, progress.Size)
+		return nil
+	})
+}
+
+func (c *hostCloner) checkpointPath(volumeID string) string {
+	return filepath.Join(c.driver.cloneCheckpointDir, volumeID+".json")
+}
+
+// readCheckpoint returns the checkpoint of the copy to a volume, nil if there is none
+func (c *hostCloner) readCheckpoint(volumeID string) (*cloneCheckpoint, error) {
+	checkpoint := &cloneCheckpoint{}
+	found, err := readCheckpointFile(c.checkpointPath(volumeID), checkpoint)
+	if err != nil || !found {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (c *hostCloner) writeCheckpoint(volumeID string, checkpoint *cloneCheckpoint) error {
+	return writeCheckpointFile(c.checkpointPath(volumeID), checkpoint)
+}
+
+// readCheckpointFile reads the checkpoint of a job running through the host of the controller, and
+// returns whether there is one
+func readCheckpointFile(path string, checkpoint any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return false, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// writeCheckpointFile replaces the checkpoint of a job atomically, creating its directory
+func writeCheckpointFile(path string, checkpoint any) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
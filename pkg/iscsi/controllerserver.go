@@ -18,44 +18,363 @@ package iscsi
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	klog "k8s.io/klog/v2"
 )
 
+// defaultVolumeSize is the capacity of the volumes created without capacity range
+const defaultVolumeSize int64 = 1 << 30
+
+// parameters with this prefix are added by the external-provisioner and are not passed to the node
+const provisionerParameterPrefix = "csi.storage.k8s.io/"
+
+// snapshotIDSeparator separates the escaped LUN ID from the escaped backend snapshot ID in the
+// snapshot IDs, as the backends only find a snapshot from its LUN
+const snapshotIDSeparator = "/"
+
 type ControllerServer struct {
 	Driver *driver
 	csi.UnimplementedControllerServer
 }
 
 func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil {
+		return nil, status.Error(codes.Unimplemented, "no backend configured")
+	}
+	name := req.GetName()
+	if len(name) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume name missing in request")
+	}
+	if len(req.GetVolumeCapabilities()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume capabilities missing in request")
+	}
+	for _, capability := range req.GetVolumeCapabilities() {
+		if err := cs.Driver.validateVolumeCapability(capability); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	formatPolicy, err := parseFormatPolicy(req.GetParameters()["formatPolicy"])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if _, err := parseFsckPolicy(req.GetParameters()["fsckPolicy"]); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	wipePolicy, hasWipePolicy := req.GetParameters()["wipePolicy"]
+	wipeMode, err := lunio.ParseWipePolicy(wipePolicy)
+	if hasWipePolicy && err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if hasWipePolicy && cs.Driver.wiper == nil {
+		return nil, status.Error(codes.InvalidArgument, "wipePolicy requires a wipe checkpoint directory")
+	}
+	if caps := cs.Driver.backend.Capabilities(); wipeMode != "" && (!caps.Get || caps.Wipe) {
+		return nil, status.Error(codes.InvalidArgument, "backend cannot look up the LUNs to wipe or wipes them itself, wipePolicy must be none")
+	}
+
+	requiredBytes := req.GetCapacityRange().GetRequiredBytes()
+	limitBytes := req.GetCapacityRange().GetLimitBytes()
+	if limitBytes > 0 && requiredBytes > limitBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "required bytes %d exceed limit bytes %d", requiredBytes, limitBytes)
+	}
+	if requiredBytes == 0 {
+		requiredBytes = defaultVolumeSize
+		if limitBytes > 0 && limitBytes < requiredBytes {
+			requiredBytes = limitBytes
+		}
+	}
+
+	caps := cs.Driver.backend.Capabilities()
+	createReq := &backend.CreateLUNRequest{
+		Name:          name,
+		RequiredBytes: requiredBytes,
+		LimitBytes:    limitBytes,
+		Parameters:    req.GetParameters(),
+		Requisite:     backendTopologies(req.GetAccessibilityRequirements().GetRequisite()),
+		Preferred:     backendTopologies(req.GetAccessibilityRequirements().GetPreferred()),
+	}
+	// the source volume of a clone that is copied through the host
+	var sourceVolume *backend.LUN
+	if source := req.GetVolumeContentSource(); source != nil {
+		switch {
+		case source.GetSnapshot() != nil:
+			if !caps.Snapshot {
+				return nil, status.Error(codes.InvalidArgument, "backend does not support snapshots")
+			}
+			ref, err := parseSnapshotID(source.GetSnapshot().GetSnapshotId())
+			if err != nil {
+				return nil, status.Errorf(codes.NotFound, "snapshot %s not found: %v", source.GetSnapshot().GetSnapshotId(), err)
+			}
+			createReq.SourceSnapshot = ref
+		case source.GetVolume() != nil:
+			sourceID := source.GetVolume().GetVolumeId()
+			if caps.Clone && !caps.Get {
+				// the backend checks the source of its clones
+				createReq.SourceLUNID = sourceID
+				break
+			}
+			if !caps.Clone && (!caps.Get || cs.Driver.cloneCheckpointDir == "") {
+				return nil, status.Error(codes.InvalidArgument, "backend does not clone volumes and the host copy is disabled")
+			}
+			sourceLUN, err := cs.Driver.backend.GetLUN(ctx, sourceID)
+			if err != nil {
+				return nil, backendError(err)
+			}
+			if limitBytes > 0 && sourceLUN.CapacityBytes > limitBytes {
+				return nil, status.Errorf(codes.OutOfRange, "volume %s of %d bytes exceeds limit bytes %d", sourceID, sourceLUN.CapacityBytes, limitBytes)
+			}
+			createReq.RequiredBytes = max(requiredBytes, sourceLUN.CapacityBytes)
+			if caps.Clone {
+				createReq.SourceLUNID = sourceID
+			} else {
+				sourceVolume = sourceLUN
+			}
+		default:
+			return nil, status.Error(codes.InvalidArgument, "Volume content source is not supported")
+		}
+	}
+
+	// the retries of the creation are serialized by the name of the volume, and the rest by its ID as the
+	// other operations on the volume. Both keys are never held at once, as their hashed locks may be the
+	// same.
+	cs.Driver.volumeLocks.LockKey(name)
+	lun, err := cs.Driver.backend.CreateLUN(ctx, createReq)
+	_ = cs.Driver.volumeLocks.UnlockKey(name)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	cs.Driver.volumeLocks.LockKey(lun.ID)
+	defer func() { _ = cs.Driver.volumeLocks.UnlockKey(lun.ID) }()
+
+	if hasWipePolicy {
+		if err := cs.Driver.wiper.setPolicy(lun.ID, wipeMode); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to record the wipe policy of volume %s: %v", lun.ID, err)
+		}
+	}
+	if sourceVolume != nil {
+		if err := cs.Driver.cloner.clone(sourceVolume.ID, sourceVolume, lun); err != nil {
+			return nil, err
+		}
+	}
+	volumeContext, err := buildVolumeContext(req.GetParameters(), lun)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if formatPolicy == formatPolicyAlwaysOnCreate && req.GetVolumeContentSource() != nil {
+		// the filesystem copied from the source must not be formatted on first use
+		volumeContext["formatPolicy"] = formatPolicyIfEmpty
+	}
+
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:           lun.ID,
+			CapacityBytes:      lun.CapacityBytes,
+			VolumeContext:      volumeContext,
+			ContentSource:      req.GetVolumeContentSource(),
+			AccessibleTopology: csiTopologies(lun.Topology),
+		},
+	}, nil
 }
 
 func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil {
+		return nil, status.Error(codes.Unimplemented, "no backend configured")
+	}
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+
+	cs.Driver.volumeLocks.LockKey(volumeID)
+	defer func() { _ = cs.Driver.volumeLocks.UnlockKey(volumeID) }()
+
+	// a copy to the volume must not write to the LUN once deleted
+	if err := cs.Driver.cloner.cancel(volumeID); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if cs.Driver.wiper != nil {
+		// the data of the volume must not leak to the next volume given the LUN
+		if err := cs.Driver.wiper.wipe(ctx, volumeID); err != nil {
+			return nil, err
+		}
+	}
+	if err := cs.Driver.backend.DeleteLUN(ctx, volumeID); err != nil && !errors.Is(err, backend.ErrNotFound) {
+		return nil, backendError(err)
+	}
+	if cs.Driver.wiper != nil {
+		if err := cs.Driver.wiper.cancel(volumeID); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return &csi.DeleteVolumeResponse{}, nil
 }
 
+// ControllerPublishVolume grants the initiator of the node access to the LUN of the volume, and
+// returns the target of the LUN and the CHAP credentials of the node in the publish context
 func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().ACL {
+		return nil, status.Error(codes.Unimplemented, "backend does not manage the access of the initiators")
+	}
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	if len(req.GetNodeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Node ID missing in request")
+	}
+	if req.GetVolumeCapability() == nil {
+		return nil, status.Error(codes.InvalidArgument, "Volume capability missing in request")
+	}
+	host, err := cs.Driver.nodeHost(ctx, req.GetNodeId())
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, status.Errorf(codes.Internal, "failed to look up initiator name of node %s: %v", host.NodeID, err)
+	}
+	if host.InitiatorIQN == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "initiator name of node %s is unknown", host.NodeID)
+	}
+
+	cs.Driver.volumeLocks.LockKey(volumeID)
+	defer func() { _ = cs.Driver.volumeLocks.UnlockKey(volumeID) }()
+
+	access, err := cs.Driver.backend.GrantInitiator(ctx, volumeID, host)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	publishContext, err := access.Target.Context()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if access.CHAP != nil {
+		publishContext[publishContextCHAPUser] = access.CHAP.User
+		publishContext[publishContextCHAPPassword] = access.CHAP.Password
+	}
+
+	return &csi.ControllerPublishVolumeResponse{
+		PublishContext: publishContext,
+	}, nil
 }
 
+// ControllerUnpublishVolume removes the access of the initiator of the node to the LUN of the volume,
+// which fences the node off the LUN
 func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().ACL {
+		return nil, status.Error(codes.Unimplemented, "backend does not manage the access of the initiators")
+	}
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	if len(req.GetNodeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Node ID missing in request")
+	}
+	cs.Driver.volumeLocks.LockKey(volumeID)
+	defer func() { _ = cs.Driver.volumeLocks.UnlockKey(volumeID) }()
+
+	host, err := cs.publishedHost(ctx, volumeID, req.GetNodeId())
+	if err != nil {
+		return nil, err
+	}
+	// the initiator has no access to a LUN that is gone
+	if err := cs.Driver.backend.RevokeInitiator(ctx, volumeID, host); err != nil && !errors.Is(err, backend.ErrNotFound) {
+		return nil, backendError(err)
+	}
+	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
 
 func (cs *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil {
+		return nil, status.Error(codes.Unimplemented, "no backend configured")
+	}
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	if len(req.GetVolumeCapabilities()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume capabilities missing in request")
+	}
+	if cs.Driver.backend.Capabilities().Get {
+		if _, err := cs.Driver.backend.GetLUN(ctx, volumeID); err != nil {
+			return nil, backendError(err)
+		}
+	}
+
+	for _, capability := range req.GetVolumeCapabilities() {
+		if err := cs.Driver.validateVolumeCapability(capability); err != nil {
+			return &csi.ValidateVolumeCapabilitiesResponse{Message: err.Error()}, nil
+		}
+	}
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      req.GetVolumeContext(),
+			VolumeCapabilities: req.GetVolumeCapabilities(),
+			Parameters:         req.GetParameters(),
+			MutableParameters:  req.GetMutableParameters(),
+		},
+	}, nil
 }
 
+// ListVolumes returns the volumes of the backend by ID, the token of the next page is the offset of
+// its first volume
 func (cs *ControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().List {
+		return nil, status.Error(codes.Unimplemented, "backend does not list volumes")
+	}
+	luns, err := cs.listLUNs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start, end, nextToken, err := paginate(len(luns), req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*csi.ListVolumesResponse_Entry, 0, end-start)
+	for _, lun := range luns[start:end] {
+		volume, err := csiVolume(lun)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		entries = append(entries, &csi.ListVolumesResponse_Entry{
+			Volume: volume,
+			Status: &csi.ListVolumesResponse_VolumeStatus{
+				PublishedNodeIds: cs.publishedNodeIDs(lun),
+			},
+		})
+	}
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
 }
 
+// GetCapacity returns the capacity of the backend available to the volumes created with the given
+// StorageClass parameters and accessible from the given topology, or from any node without topology
 func (cs *ControllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().Capacity {
+		return nil, status.Error(codes.Unimplemented, "backend does not report its capacity")
+	}
+
+	capacity, err := cs.Driver.backend.Capacity(ctx, req.GetParameters(), req.GetAccessibleTopology().GetSegments())
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: capacity,
+	}, nil
 }
 
 // ControllerGetCapabilities implements the default GRPC callout.
@@ -69,21 +388,422 @@ func (cs *ControllerServer) ControllerGetCapabilities(ctx context.Context, req *
 }
 
 func (cs *ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().Snapshot {
+		return nil, status.Error(codes.Unimplemented, "backend does not support snapshots")
+	}
+	name := req.GetName()
+	if len(name) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot name missing in request")
+	}
+	sourceVolumeID := req.GetSourceVolumeId()
+	if len(sourceVolumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Source volume ID missing in request")
+	}
+
+	cs.Driver.volumeLocks.LockKey(sourceVolumeID)
+	defer func() { _ = cs.Driver.volumeLocks.UnlockKey(sourceVolumeID) }()
+
+	snapshot, err := cs.Driver.backend.CreateSnapshot(ctx, sourceVolumeID, name)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return &csi.CreateSnapshotResponse{
+		Snapshot: csiSnapshot(snapshot),
+	}, nil
 }
 
 func (cs *ControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().Snapshot {
+		return nil, status.Error(codes.Unimplemented, "backend does not support snapshots")
+	}
+	snapshotID := req.GetSnapshotId()
+	if len(snapshotID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot ID missing in request")
+	}
+	ref, err := parseSnapshotID(snapshotID)
+	if err != nil {
+		// a snapshot with an ID this driver does not return cannot exist
+		klog.Warningf("DeleteSnapshot: ignoring invalid snapshot ID %s: %v", snapshotID, err)
+		return &csi.DeleteSnapshotResponse{}, nil
+	}
+
+	cs.Driver.volumeLocks.LockKey(ref.LUNID)
+	defer func() { _ = cs.Driver.volumeLocks.UnlockKey(ref.LUNID) }()
+
+	if err := cs.Driver.backend.DeleteSnapshot(ctx, ref.LUNID, ref.SnapshotID); err != nil && !errors.Is(err, backend.ErrNotFound) {
+		return nil, backendError(err)
+	}
+	return &csi.DeleteSnapshotResponse{}, nil
 }
 
+// ListSnapshots returns the snapshots of the backend by ID, the token of the next page is the
+// offset of its first snapshot
 func (cs *ControllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().ListSnapshots {
+		return nil, status.Error(codes.Unimplemented, "backend does not list snapshots")
+	}
+	var snapshots []*backend.Snapshot
+	switch {
+	case req.GetSnapshotId() != "":
+		ref, err := parseSnapshotID(req.GetSnapshotId())
+		if err != nil {
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		all, err := cs.Driver.backend.ListSnapshots(ctx, ref.LUNID)
+		if err != nil && !errors.Is(err, backend.ErrNotFound) {
+			return nil, backendError(err)
+		}
+		for _, snapshot := range all {
+			if snapshot.ID == ref.SnapshotID {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+	default:
+		var err error
+		snapshots, err = cs.Driver.backend.ListSnapshots(ctx, req.GetSourceVolumeId())
+		if errors.Is(err, backend.ErrNotFound) {
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		if err != nil {
+			return nil, backendError(err)
+		}
+	}
+
+	entries := make([]*csi.ListSnapshotsResponse_Entry, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		entries = append(entries, &csi.ListSnapshotsResponse_Entry{Snapshot: csiSnapshot(snapshot)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Snapshot.SnapshotId < entries[j].Snapshot.SnapshotId
+	})
+	start, end, nextToken, err := paginate(len(entries), req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+
+	return &csi.ListSnapshotsResponse{
+		Entries:   entries[start:end],
+		NextToken: nextToken,
+	}, nil
 }
 
+// ControllerExpandVolume expands the LUN of a volume, the node then rescans its devices and grows
+// its filesystem
 func (cs *ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().Expand {
+		return nil, status.Error(codes.Unimplemented, "backend does not expand volumes")
+	}
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	if req.GetCapacityRange() == nil {
+		return nil, status.Error(codes.InvalidArgument, "Capacity range missing in request")
+	}
+	requiredBytes := req.GetCapacityRange().GetRequiredBytes()
+	limitBytes := req.GetCapacityRange().GetLimitBytes()
+	if limitBytes > 0 && requiredBytes > limitBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "required bytes %d exceed limit bytes %d", requiredBytes, limitBytes)
+	}
+
+	cs.Driver.volumeLocks.LockKey(volumeID)
+	defer func() { _ = cs.Driver.volumeLocks.UnlockKey(volumeID) }()
+
+	capacity, err := cs.Driver.backend.ExpandLUN(ctx, volumeID, requiredBytes)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	if limitBytes > 0 && capacity > limitBytes {
+		return nil, status.Errorf(codes.OutOfRange, "volume %s of %d bytes exceeds limit bytes %d", volumeID, capacity, limitBytes)
+	}
+
+	// the node expansion rescans the devices of the volume, even without filesystem to grow
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         capacity,
+		NodeExpansionRequired: true,
+	}, nil
 }
 
 func (cs *ControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().Get {
+		return nil, status.Error(codes.Unimplemented, "backend does not look up volumes")
+	}
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+
+	lun, err := cs.Driver.backend.GetLUN(ctx, req.GetVolumeId())
+	if err != nil {
+		return nil, backendError(err)
+	}
+	volume, err := csiVolume(lun)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &csi.ControllerGetVolumeResponse{
+		Volume: volume,
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			PublishedNodeIds: cs.publishedNodeIDs(lun),
+		},
+	}, nil
+}
+
+// ControllerGetVolumeHealth reports the adverse conditions of the LUN of a volume known to the backend
+func (cs *ControllerServer) ControllerGetVolumeHealth(ctx context.Context, req *csi.ControllerGetVolumeHealthRequest) (*csi.ControllerGetVolumeHealthResponse, error) {
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().Get {
+		return nil, status.Error(codes.Unimplemented, "backend does not look up volumes")
+	}
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+
+	lun, err := cs.Driver.backend.GetLUN(ctx, req.GetVolumeId())
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return &csi.ControllerGetVolumeHealthResponse{
+		VolumeHealth: csiVolumeHealth(lun),
+	}, nil
+}
+
+// ControllerListVolumeHealth returns the volumes of the backend with adverse conditions by ID, the
+// token of the next page is the offset of its first volume
+func (cs *ControllerServer) ControllerListVolumeHealth(ctx context.Context, req *csi.ControllerListVolumeHealthRequest) (*csi.ControllerListVolumeHealthResponse, error) {
+	if cs.Driver.backend == nil || !cs.Driver.backend.Capabilities().List {
+		return nil, status.Error(codes.Unimplemented, "backend does not list volumes")
+	}
+	luns, err := cs.listLUNs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var unhealthy []*backend.LUN
+	for _, lun := range luns {
+		if len(lun.Health) > 0 {
+			unhealthy = append(unhealthy, lun)
+		}
+	}
+	start, end, nextToken, err := paginate(len(unhealthy), req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*csi.VolumeHealth, 0, end-start)
+	for _, lun := range unhealthy[start:end] {
+		entries = append(entries, csiVolumeHealth(lun))
+	}
+	return &csi.ControllerListVolumeHealthResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
+}
+
+// listLUNs returns the LUNs of the backend by ID
+func (cs *ControllerServer) listLUNs(ctx context.Context) ([]*backend.LUN, error) {
+	luns, err := cs.Driver.backend.ListLUNs(ctx)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	sort.Slice(luns, func(i, j int) bool { return luns[i].ID < luns[j].ID })
+	return luns, nil
+}
+
+// publishedHost returns the host a volume is published on. Its initiator name is looked up in the node
+// object, or in the hosts granted access to the LUN of the volume if the node object is gone, e.g. when
+// the node is fenced after a failure.
+func (cs *ControllerServer) publishedHost(ctx context.Context, volumeID, nodeID string) (backend.Host, error) {
+	host, err := cs.Driver.nodeHost(ctx, nodeID)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return host, status.Errorf(codes.Internal, "failed to look up initiator name of node %s: %v", nodeID, err)
+	}
+	if host.InitiatorIQN != "" || !cs.Driver.backend.Capabilities().Hosts {
+		return host, nil
+	}
+
+	lun, err := cs.Driver.backend.GetLUN(ctx, volumeID)
+	if errors.Is(err, backend.ErrNotFound) {
+		return host, nil
+	}
+	if err != nil {
+		return host, backendError(err)
+	}
+	for _, granted := range lun.Hosts {
+		if granted.NodeID == nodeID {
+			return granted, nil
+		}
+	}
+	return host, nil
+}
+
+// publishedNodeIDs returns the IDs of the nodes granted access to a LUN, nil if the backend does not
+// report them
+func (cs *ControllerServer) publishedNodeIDs(lun *backend.LUN) []string {
+	if !cs.Driver.backend.Capabilities().Hosts {
+		return nil
+	}
+	nodeIDs := []string{}
+	for _, host := range lun.Hosts {
+		// the access granted outside of the driver is not a publication
+		if host.NodeID != "" {
+			nodeIDs = append(nodeIDs, host.NodeID)
+		}
+	}
+	sort.Strings(nodeIDs)
+	return nodeIDs
+}
+
+// paginate returns the range of the page of a list of n entries starting at the offset in token, of
+// at most maxEntries entries if not 0, and the token of the next page
+func paginate(n int, token string, maxEntries int32) (int, int, string, error) {
+	if maxEntries < 0 {
+		return 0, 0, "", status.Errorf(codes.InvalidArgument, "invalid max entries %d", maxEntries)
+	}
+	start := 0
+	if token != "" {
+		offset, err := strconv.Atoi(token)
+		if err != nil || offset < 0 || offset > n {
+			return 0, 0, "", status.Errorf(codes.Aborted, "invalid starting token %q", token)
+		}
+		start = offset
+	}
+	end := n
+	if maxEntries > 0 && start+int(maxEntries) < end {
+		end = start + int(maxEntries)
+	}
+	if end < n {
+		return start, end, strconv.Itoa(end), nil
+	}
+	return start, end, "", nil
+}
+
+// csiVolume converts a LUN to a CSI volume
+func csiVolume(lun *backend.LUN) (*csi.Volume, error) {
+	volumeContext, err := buildVolumeContext(nil, lun)
+	if err != nil {
+		return nil, err
+	}
+	return &csi.Volume{
+		VolumeId:           lun.ID,
+		CapacityBytes:      lun.CapacityBytes,
+		VolumeContext:      volumeContext,
+		AccessibleTopology: csiTopologies(lun.Topology),
+	}, nil
+}
+
+// backendTopologies converts CSI topologies to backend topologies
+func backendTopologies(topologies []*csi.Topology) []backend.Topology {
+	var segments []backend.Topology
+	for _, t := range topologies {
+		segments = append(segments, t.GetSegments())
+	}
+	return segments
+}
+
+// csiTopologies converts the topologies a LUN is accessible from to CSI topologies, nil if it is
+// accessible from every node
+func csiTopologies(segments []backend.Topology) []*csi.Topology {
+	var topologies []*csi.Topology
+	for _, segment := range segments {
+		topologies = append(topologies, &csi.Topology{Segments: segment})
+	}
+	return topologies
+}
+
+// csiVolumeHealth converts the adverse conditions of a LUN to CSI health statuses
+func csiVolumeHealth(lun *backend.LUN) *csi.VolumeHealth {
+	health := &csi.VolumeHealth{VolumeId: lun.ID}
+	for _, condition := range lun.Health {
+		status := csi.VolumeHealthErrorType_UNKNOWN_VOLUME_HEALTH_TYPE
+		switch condition.Status {
+		case backend.HealthDegraded:
+			status = csi.VolumeHealthErrorType_DEGRADED
+		case backend.HealthInaccessible:
+			status = csi.VolumeHealthErrorType_INACCESSIBLE
+		case backend.HealthDataLoss:
+			status = csi.VolumeHealthErrorType_DATA_LOSS
+		}
+		health.HealthStatuses = append(health.HealthStatuses, &csi.VolumeHealth_VolumeHealthEntry{
+			Status:  status,
+			Reason:  condition.Reason,
+			Message: condition.Message,
+		})
+	}
+	return health
+}
+
+// buildVolumeContext returns the volume context of a created volume: the StorageClass parameters,
+// which can hold the volume attributes parsed by the node service, the context of its LUN and the
+// target of its LUN
+func buildVolumeContext(parameters map[string]string, lun *backend.LUN) (map[string]string, error) {
+	targetContext, err := lun.Target.Context()
+	if err != nil {
+		return nil, err
+	}
+
+	volumeContext := map[string]string{}
+	for k, v := range parameters {
+		if !strings.HasPrefix(k, provisionerParameterPrefix) {
+			volumeContext[k] = v
+		}
+	}
+	for k, v := range lun.Context {
+		volumeContext[k] = v
+	}
+	for k, v := range targetContext {
+		volumeContext[k] = v
+	}
+	return volumeContext, nil
+}
+
+// csiSnapshot converts a snapshot of the backend to a CSI snapshot
+func csiSnapshot(snapshot *backend.Snapshot) *csi.Snapshot {
+	return &csi.Snapshot{
+		SnapshotId:     formatSnapshotID(snapshot.SourceLUNID, snapshot.ID),
+		SourceVolumeId: snapshot.SourceLUNID,
+		SizeBytes:      snapshot.SizeBytes,
+		CreationTime:   timestamppb.New(snapshot.CreationTime),
+		ReadyToUse:     snapshot.ReadyToUse,
+	}
+}
+
+// formatSnapshotID returns the CSI ID of the snapshot of a LUN
+func formatSnapshotID(lunID, snapshotID string) string {
+	return url.PathEscape(lunID) + snapshotIDSeparator + url.PathEscape(snapshotID)
+}
+
+// parseSnapshotID returns the LUN and the backend snapshot of a CSI snapshot ID
+func parseSnapshotID(id string) (*backend.SnapshotRef, error) {
+	escapedLUNID, escapedSnapshotID, found := strings.Cut(id, snapshotIDSeparator)
+	if !found {
+		return nil, fmt.Errorf("missing %q separator", snapshotIDSeparator)
+	}
+	lunID, err := url.PathUnescape(escapedLUNID)
+	if err != nil {
+		return nil, err
+	}
+	snapshotID, err := url.PathUnescape(escapedSnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if lunID == "" || snapshotID == "" {
+		return nil, fmt.Errorf("empty LUN or snapshot ID")
+	}
+	return &backend.SnapshotRef{LUNID: lunID, SnapshotID: snapshotID}, nil
+}
+
+// backendError converts an error returned by the backend to a gRPC error
+func backendError(err error) error {
+	switch {
+	case errors.Is(err, backend.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, backend.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, backend.ErrNotSupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, backend.ErrOutOfCapacity), errors.Is(err, backend.ErrNotAccessible):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, backend.ErrInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
 }
@@ -0,0 +1,237 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name       string
+		n          int
+		token      string
+		maxEntries int32
+		wantStart  int
+		wantEnd    int
+		wantNext   string
+		wantCode   codes.Code
+	}{
+		{
+			name:    "all entries",
+			n:       5,
+			wantEnd: 5,
+		},
+		{
+			name:       "first page",
+			n:          5,
+			maxEntries: 2,
+			wantEnd:    2,
+			wantNext:   "2",
+		},
+		{
+			name:       "middle page",
+			n:          5,
+			token:      "2",
+			maxEntries: 2,
+			wantStart:  2,
+			wantEnd:    4,
+			wantNext:   "4",
+		},
+		{
+			name:       "last page",
+			n:          5,
+			token:      "4",
+			maxEntries: 2,
+			wantStart:  4,
+			wantEnd:    5,
+		},
+		{
+			name:       "page of exactly the remaining entries",
+			n:          4,
+			token:      "2",
+			maxEntries: 2,
+			wantStart:  2,
+			wantEnd:    4,
+		},
+		{
+			name:      "token at the end",
+			n:         5,
+			token:     "5",
+			wantStart: 5,
+			wantEnd:   5,
+		},
+		{
+			name: "no entries",
+		},
+		{
+			name:     "token past the end",
+			n:        5,
+			token:    "6",
+			wantCode: codes.Aborted,
+		},
+		{
+			name:     "negative token",
+			n:        5,
+			token:    "-1",
+			wantCode: codes.Aborted,
+		},
+		{
+			name:     "invalid token",
+			n:        5,
+			token:    "next",
+			wantCode: codes.Aborted,
+		},
+		{
+			name:       "negative max entries",
+			n:          5,
+			maxEntries: -1,
+			wantCode:   codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end, next, err := paginate(test.n, test.token, test.maxEntries)
+			if code := status.Code(err); code != test.wantCode {
+				t.Fatalf("expected code %v, got %v", test.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if start != test.wantStart || end != test.wantEnd || next != test.wantNext {
+				t.Errorf("expected [%d, %d) and next token %q, got [%d, %d) and %q", test.wantStart, test.wantEnd, test.wantNext, start, end, next)
+			}
+		})
+	}
+}
+
+func TestSnapshotID(t *testing.T) {
+	tests := []struct {
+		name       string
+		lunID      string
+		snapshotID string
+		wantID     string
+	}{
+		{
+			name:       "plain IDs",
+			lunID:      "pvc-1",
+			snapshotID: "snapshot-1",
+			wantID:     "pvc-1/snapshot-1",
+		},
+		{
+			name:       "IDs with separators",
+			lunID:      "vg/lv",
+			snapshotID: "pool/snap@1",
+			wantID:     "vg%2Flv/pool%2Fsnap@1",
+		},
+		{
+			name:       "IDs with escapes",
+			lunID:      "a%2Fb",
+			snapshotID: "c d",
+			wantID:     "a%252Fb/c%20d",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id := formatSnapshotID(test.lunID, test.snapshotID)
+			if id != test.wantID {
+				t.Errorf("expected ID %q, got %q", test.wantID, id)
+			}
+			ref, err := parseSnapshotID(id)
+			if err != nil {
+				t.Fatalf("failed to parse %q: %v", id, err)
+			}
+			if ref.LUNID != test.lunID || ref.SnapshotID != test.snapshotID {
+				t.Errorf("expected LUN %q and snapshot %q, got %+v", test.lunID, test.snapshotID, ref)
+			}
+		})
+	}
+}
+
+func TestParseInvalidSnapshotID(t *testing.T) {
+	for _, id := range []string{"", "pvc-1", "/snapshot-1", "pvc-1/", "pvc-1/%zz", "%zz/snapshot-1"} {
+		t.Run(id, func(t *testing.T) {
+			if ref, err := parseSnapshotID(id); err == nil {
+				t.Errorf("expected an error, got %+v", ref)
+			}
+		})
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{
+			name:     "not found",
+			err:      fmt.Errorf("LUN pvc-1: %w", backend.ErrNotFound),
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "already exists",
+			err:      fmt.Errorf("LUN pvc-1: %w", backend.ErrAlreadyExists),
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name:     "not supported",
+			err:      fmt.Errorf("snapshots: %w", backend.ErrNotSupported),
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name:     "out of capacity",
+			err:      fmt.Errorf("LUN pvc-1: %w", backend.ErrOutOfCapacity),
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:     "not accessible",
+			err:      fmt.Errorf("LUN pvc-1: %w", backend.ErrNotAccessible),
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:     "in progress",
+			err:      fmt.Errorf("wipe of LUN lun1: %w", backend.ErrInProgress),
+			wantCode: codes.Aborted,
+		},
+		{
+			name:     "other error",
+			err:      errors.New("connection refused"),
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := backendError(test.err)
+			if code := status.Code(err); code != test.wantCode {
+				t.Errorf("expected code %v, got %v", test.wantCode, code)
+			}
+			if msg := status.Convert(err).Message(); msg != test.err.Error() {
+				t.Errorf("expected message %q, got %q", test.err.Error(), msg)
+			}
+		})
+	}
+}
@@ -17,13 +17,60 @@ limitations under the License.
 package iscsi
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/scsipr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+	"k8s.io/utils/keymutex"
 )
 
+// DriverOptions defines driver parameters specified in driver deployment
+type DriverOptions struct {
+	NodeID                 string
+	Endpoint               string
+	PathHealInterval       time.Duration
+	MetricsAddress         string
+	ForceDetach            bool
+	ForceDetachGracePeriod time.Duration
+	// Backend provisions the volumes of the controller service, nil for static provisioning only
+	Backend backend.Backend
+	// InitiatorNameFile is the open-iscsi file holding the initiator name of the node
+	InitiatorNameFile string
+	// CloneCheckpointDir holds the progress of the volumes cloned through the host of the controller,
+	// empty disables the host copy
+	CloneCheckpointDir string
+	// Topology are the topology segments of the node, the keys without prefix are prefixed with
+	// topology.iscsi.csi.k8s.io/
+	Topology map[string]string
+	// TopologyNodeLabels are the labels of the node object added to the topology segments of the node
+	TopologyNodeLabels []string
+	// PortalGroups are the portals of the storage networks by group name, the reachability of each
+	// group from the node is added to its topology segments
+	PortalGroups       map[string][]string
+	PortalProbeTimeout time.Duration
+	// FsckTimeout bounds the check or repair of the filesystems of the volumes with a fsckPolicy
+	FsckTimeout time.Duration
+	// WipePolicy is how the controller wipes the LUNs of the deleted volumes without a wipePolicy
+	// parameter, empty to not wipe them. It requires a backend with the Get capability.
+	WipePolicy lunio.WipeMode
+	// WipeCheckpointDir holds the progress of the wipes
+	WipeCheckpointDir string
+}
+
 type driver struct {
 	name     string
 	nodeID   string
@@ -31,6 +78,44 @@ type driver struct {
 	endpoint string
 	cap      []*csi.VolumeCapability_AccessMode
 	cscap    []*csi.ControllerServiceCapability
+	nscap    []*csi.NodeServiceCapability
+
+	pathHealInterval time.Duration
+	metricsAddress   string
+	// serializes the operations on a volume, as the path healer runs concurrently with the node server
+	volumeLocks keymutex.KeyMutex
+
+	forceDetach            bool
+	forceDetachGracePeriod time.Duration
+	// start of the first unpublish attempt of the volumes that are not unpublished yet
+	unpublishAttempts map[string]time.Time
+	// volumes whose detach outlived the grace period and still runs in the background
+	detachesInFlight      map[string]bool
+	unpublishAttemptsLock sync.Mutex
+
+	backend backend.Backend
+	// initiator name of the node, empty if unknown
+	initiatorName string
+
+	cloneCheckpointDir string
+	cloner             *hostCloner
+	wipeCheckpointDir  string
+	// wipes the LUNs of the deleted volumes, nil without backend or checkpoint directory
+	wiper *hostWiper
+
+	topology           map[string]string
+	topologyNodeLabels []string
+	portalGroups       map[string][]string
+	portalProbeTimeout time.Duration
+
+	// issues the persistent reservation commands fencing the volumes
+	reservations scsipr.Interface
+
+	fsckTimeout time.Duration
+	// client of the API server, nil outside of a cluster
+	client kubernetes.Interface
+	// records the events of the volumes, nil outside of a cluster
+	events record.EventRecorder
 }
 
 const (
@@ -39,24 +124,120 @@ const (
 
 var version = "0.2.0"
 
-func NewDriver(nodeID, endpoint string) *driver {
-	klog.V(1).Infof("driver: %s version: %s nodeID: %s endpoint: %s", driverName, version, nodeID, endpoint)
+// newClient returns a client of the API server, nil when the driver does not run in a cluster
+func newClient() kubernetes.Interface {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		klog.V(2).Infof("not running in a cluster: %v", err)
+		return nil
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		klog.Warningf("failed to create the client of the API server: %v", err)
+		return nil
+	}
+	return client
+}
+
+func NewDriver(options *DriverOptions) *driver {
+	klog.V(1).Infof("driver: %s version: %s nodeID: %s endpoint: %s", driverName, version, options.NodeID, options.Endpoint)
 
 	d := &driver{
-		name:     driverName,
-		version:  version,
-		nodeID:   nodeID,
-		endpoint: endpoint,
+		name:             driverName,
+		version:          version,
+		nodeID:           options.NodeID,
+		endpoint:         options.Endpoint,
+		pathHealInterval: options.PathHealInterval,
+		metricsAddress:   options.MetricsAddress,
+		volumeLocks:      keymutex.NewHashed(0),
+
+		forceDetach:            options.ForceDetach,
+		forceDetachGracePeriod: options.ForceDetachGracePeriod,
+		unpublishAttempts:      map[string]time.Time{},
+		detachesInFlight:       map[string]bool{},
+		backend:                options.Backend,
+		cloneCheckpointDir:     options.CloneCheckpointDir,
+		wipeCheckpointDir:      options.WipeCheckpointDir,
+
+		topology:           map[string]string{},
+		topologyNodeLabels: options.TopologyNodeLabels,
+		portalGroups:       options.PortalGroups,
+		portalProbeTimeout: options.PortalProbeTimeout,
+		reservations:       scsipr.New(exec.New()),
+		fsckTimeout:        options.FsckTimeout,
+		client:             newClient(),
+	}
+	d.events = newEventRecorder(d.client, options.NodeID)
+	d.cloner = newHostCloner(d)
+	if d.backend != nil && d.wipeCheckpointDir != "" {
+		d.wiper = newHostWiper(d, options.WipePolicy)
+	}
+	for key, value := range options.Topology {
+		d.topology[topologyKey(key)] = value
+	}
+
+	if options.InitiatorNameFile != "" {
+		initiatorName, err := readInitiatorName(options.InitiatorNameFile)
+		if err != nil {
+			klog.Warningf("failed to read initiator name, the controller cannot grant this node access to LUNs: %v", err)
+		}
+		d.initiatorName = initiatorName
 	}
 
 	if err := os.MkdirAll(fmt.Sprintf("/var/run/%s", driverName), 0o755); err != nil {
 		panic(err)
 	}
-	d.AddVolumeCapabilityAccessModes([]csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER})
-	// iSCSI plugin does not support ControllerServiceCapability now.
-	// If support is added, it should set to appropriate
-	// ControllerServiceCapability RPC types.
-	d.AddControllerServiceCapabilities([]csi.ControllerServiceCapability_RPC_Type{csi.ControllerServiceCapability_RPC_UNKNOWN})
+	d.AddVolumeCapabilityAccessModes([]csi.VolumeCapability_AccessMode_Mode{
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+	})
+	// Without backend, the iSCSI plugin only attaches statically provisioned volumes
+	// and does not support any ControllerServiceCapability.
+	if d.backend != nil {
+		caps := d.backend.Capabilities()
+		cl := []csi.ControllerServiceCapability_RPC_Type{
+			csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+			csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+		}
+		if caps.Clone || (caps.Get && d.cloneCheckpointDir != "") {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_CLONE_VOLUME)
+		}
+		if caps.ACL {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME)
+		}
+		if caps.Expand {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_EXPAND_VOLUME)
+		}
+		if caps.Capacity {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_GET_CAPACITY)
+		}
+		if caps.Snapshot {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT)
+		}
+		if caps.ListSnapshots {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS)
+		}
+		if caps.Get {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_GET_VOLUME, csi.ControllerServiceCapability_RPC_GET_VOLUME_HEALTH)
+		}
+		if caps.List {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_LIST_VOLUMES, csi.ControllerServiceCapability_RPC_LIST_VOLUME_HEALTH)
+		}
+		if caps.List && caps.Hosts {
+			cl = append(cl, csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES)
+		}
+		d.AddControllerServiceCapabilities(cl)
+	} else {
+		d.AddControllerServiceCapabilities([]csi.ControllerServiceCapability_RPC_Type{csi.ControllerServiceCapability_RPC_UNKNOWN})
+	}
+	d.AddNodeServiceCapabilities([]csi.NodeServiceCapability_RPC_Type{
+		csi.NodeServiceCapability_RPC_GET_VOLUME_HEALTH,
+		csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+		csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+	})
 
 	return d
 }
@@ -68,11 +249,16 @@ func NewNodeServer(d *driver) *nodeServer {
 }
 
 func (d *driver) Run() {
+	if d.metricsAddress != "" {
+		go serveMetrics(d.metricsAddress)
+	}
+	if d.pathHealInterval > 0 {
+		go newPathHealer(d).run(d.pathHealInterval, make(chan struct{}))
+	}
+
 	s := NewNonBlockingGRPCServer()
 	s.Start(d.endpoint,
 		NewDefaultIdentityServer(d),
-		// iSCSI plugin has not implemented ControllerServer
-		// using default controllerserver.
 		NewControllerServer(d),
 		NewNodeServer(d))
 	s.Wait()
@@ -88,6 +274,31 @@ func (d *driver) AddVolumeCapabilityAccessModes(vc []csi.VolumeCapability_Access
 	return vca
 }
 
+// validateVolumeCapability returns why a volume capability cannot be served, nil if it can
+func (d *driver) validateVolumeCapability(capability *csi.VolumeCapability) error {
+	mode := capability.GetAccessMode().GetMode()
+	supported := false
+	for _, c := range d.cap {
+		if c.GetMode() == mode {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("access mode %s is not supported", mode)
+	}
+
+	switch {
+	case capability.GetBlock() != nil:
+		return nil
+	case capability.GetMount() == nil:
+		return fmt.Errorf("access type missing")
+	case mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER:
+		// the filesystems would be corrupted by the concurrent writes of the nodes
+		return fmt.Errorf("access mode %s requires the block access type", mode)
+	}
+	return nil
+}
+
 func (d *driver) AddControllerServiceCapabilities(cl []csi.ControllerServiceCapability_RPC_Type) {
 	var csc []*csi.ControllerServiceCapability
 
@@ -98,3 +309,94 @@ func (d *driver) AddControllerServiceCapabilities(cl []csi.ControllerServiceCapa
 
 	d.cscap = csc
 }
+
+func (d *driver) AddNodeServiceCapabilities(nl []csi.NodeServiceCapability_RPC_Type) {
+	var nsc []*csi.NodeServiceCapability
+
+	for _, n := range nl {
+		klog.Infof("enabling node service capability: %v", n.String())
+		nsc = append(nsc, NewNodeServiceCapability(n))
+	}
+
+	d.nscap = nsc
+}
+
+// remainingDetachGracePeriod records the first unpublish attempt of a volume and returns how long its
+// detach can still take before it is forced
+func (d *driver) remainingDetachGracePeriod(volumeID string) time.Duration {
+	d.unpublishAttemptsLock.Lock()
+	defer d.unpublishAttemptsLock.Unlock()
+
+	start, ok := d.unpublishAttempts[volumeID]
+	if !ok {
+		start = time.Now()
+		d.unpublishAttempts[volumeID] = start
+	}
+	return d.forceDetachGracePeriod - time.Since(start)
+}
+
+// clearUnpublishAttempt forgets the unpublish attempts of a volume once it is unpublished
+func (d *driver) clearUnpublishAttempt(volumeID string) {
+	d.unpublishAttemptsLock.Lock()
+	defer d.unpublishAttemptsLock.Unlock()
+
+	delete(d.unpublishAttempts, volumeID)
+}
+
+// runDetach runs the detach of a volume for at most timeout, and keeps track of it once it is given up on,
+// as it goes on in the background and must not race a new publish or detach of the volume
+func (d *driver) runDetach(volumeID string, timeout time.Duration, detach func() error) error {
+	d.unpublishAttemptsLock.Lock()
+	d.detachesInFlight[volumeID] = true
+	d.unpublishAttemptsLock.Unlock()
+
+	return iscsiLib.RunWithTimeout("detach of volume "+volumeID, timeout, func() error {
+		defer func() {
+			d.unpublishAttemptsLock.Lock()
+			defer d.unpublishAttemptsLock.Unlock()
+			delete(d.detachesInFlight, volumeID)
+		}()
+		return detach()
+	})
+}
+
+// detachInFlight returns whether a detach of the volume given up on still runs
+func (d *driver) detachInFlight(volumeID string) bool {
+	d.unpublishAttemptsLock.Lock()
+	defer d.unpublishAttemptsLock.Unlock()
+
+	return d.detachesInFlight[volumeID]
+}
+
+// checkNoDetachInFlight fails with Aborted while a detach of the volume given up on still runs
+func (d *driver) checkNoDetachInFlight(volumeID string) error {
+	if d.detachInFlight(volumeID) {
+		return status.Errorf(codes.Aborted, "detach of volume %s still in progress", volumeID)
+	}
+	return nil
+}
+
+// grantHostAccess gives the host of the controller access to a LUN, to copy or wipe its content, if
+// the backend manages the access of the initiators
+func (d *driver) grantHostAccess(ctx context.Context, lun *backend.LUN) (*backend.Access, error) {
+	if !d.backend.Capabilities().ACL {
+		return &backend.Access{Target: lun.Target}, nil
+	}
+	if d.initiatorName == "" {
+		return nil, fmt.Errorf("initiator name of the controller host is unknown")
+	}
+	return d.backend.GrantInitiator(ctx, lun.ID, d.controllerHost())
+}
+
+// revokeHostAccess revokes the access of the host of the controller to a LUN
+func (d *driver) revokeHostAccess(lun *backend.LUN) error {
+	if !d.backend.Capabilities().ACL {
+		return nil
+	}
+	// the access is revoked even if the job using it was cancelled
+	return d.backend.RevokeInitiator(context.Background(), lun.ID, d.controllerHost())
+}
+
+func (d *driver) controllerHost() backend.Host {
+	return backend.Host{NodeID: d.nodeID, InitiatorIQN: d.initiatorName}
+}
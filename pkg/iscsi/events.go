@@ -0,0 +1,62 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	klog "k8s.io/klog/v2"
+)
+
+// keys of the pod a volume is published for in the volume context, set when the CSIDriver object
+// enables podInfoOnMount
+const (
+	podNameKey      = "csi.storage.k8s.io/pod.name"
+	podNamespaceKey = "csi.storage.k8s.io/pod.namespace"
+	podUIDKey       = "csi.storage.k8s.io/pod.uid"
+)
+
+// newEventRecorder returns a recorder of the events of the driver on the node, nil without a client
+// of the API server
+func newEventRecorder(client kubernetes.Interface, nodeID string) record.EventRecorder {
+	if client == nil {
+		klog.V(2).Infof("not running in a cluster, events are not recorded")
+		return nil
+	}
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: driverName, Host: nodeID})
+}
+
+// eventObject returns the object the events of a volume publication are recorded on: the pod the
+// volume is published for if the volume context names it, else the node
+func eventObject(volumeContext map[string]string, nodeID string) *v1.ObjectReference {
+	if name := volumeContext[podNameKey]; name != "" {
+		return &v1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: volumeContext[podNamespaceKey],
+			Name:      name,
+			UID:       types.UID(volumeContext[podUIDKey]),
+		}
+	}
+	// the kubelet records the events of the node with its name as UID
+	return &v1.ObjectReference{Kind: "Node", Name: nodeID, UID: types.UID(nodeID)}
+}
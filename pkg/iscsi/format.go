@@ -0,0 +1,155 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	klog "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+const (
+	// formatPolicyIfEmpty formats the devices that hold no signature, the default
+	formatPolicyIfEmpty = "ifEmpty"
+	// formatPolicyNever never formats the devices, they must already hold the filesystem
+	formatPolicyNever = "never"
+	// formatPolicyAlwaysOnCreate formats the device the first time the volume is published,
+	// whatever it holds
+	formatPolicyAlwaysOnCreate = "always-on-create"
+)
+
+// defaultFSType is the filesystem of the volumes that do not request one, like SafeFormatAndMount
+const defaultFSType = "ext4"
+
+// volumeUUIDNamespace is the namespace of the filesystem UUIDs derived from the volume IDs
+var volumeUUIDNamespace = uuid.NewSHA1(uuid.NameSpaceDNS, []byte("iscsi.csi.k8s.io"))
+
+// parseFormatPolicy parses when the device of a filesystem volume is formatted
+func parseFormatPolicy(formatPolicy string) (string, error) {
+	switch formatPolicy {
+	case "":
+		return formatPolicyIfEmpty, nil
+	case formatPolicyIfEmpty, formatPolicyNever, formatPolicyAlwaysOnCreate:
+		return formatPolicy, nil
+	}
+	return "", fmt.Errorf("invalid formatPolicy %q: must be %q, %q or %q", formatPolicy, formatPolicyIfEmpty, formatPolicyNever, formatPolicyAlwaysOnCreate)
+}
+
+// volumeFilesystemUUID returns the UUID of the filesystems created by formatPolicy always-on-create,
+// which tells them apart from the filesystems the device held before the volume was created
+func volumeFilesystemUUID(volumeID string) string {
+	return uuid.NewSHA1(volumeUUIDNamespace, []byte(volumeID)).String()
+}
+
+// mkfsArgs returns the arguments of mkfs.<fsType> for a device: the defaults of SafeFormatAndMount,
+// then the mkfsOptions of the volume, then the filesystem UUID if it is set
+func mkfsArgs(fsType string, mkfsOptions []string, fsUUID, device string) ([]string, error) {
+	var args []string
+	if fsType == "ext3" || fsType == "ext4" {
+		args = append(args, "-F", "-m0")
+	}
+	args = append(args, mkfsOptions...)
+	if fsUUID != "" {
+		switch fsType {
+		case "ext2", "ext3", "ext4", "btrfs":
+			args = append(args, "-U", fsUUID)
+		case "xfs":
+			args = append(args, "-m", "uuid="+fsUUID)
+		default:
+			return nil, fmt.Errorf("formatPolicy %s does not support filesystem %s", formatPolicyAlwaysOnCreate, fsType)
+		}
+	}
+	return append(args, device), nil
+}
+
+// getFilesystemUUID returns the UUID of the filesystem of a device
+func getFilesystemUUID(executor exec.Interface, device string) (string, error) {
+	out, err := executor.Command("blkid", "-p", "-s", "UUID", "-o", "value", device).CombinedOutput()
+	if err != nil {
+		if exitErr, ok := err.(exec.ExitError); ok && exitErr.ExitStatus() == 2 {
+			return "", nil
+		}
+		return "", fmt.Errorf("blkid of %s failed: %v, output: %s", device, err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// formatDevice formats the device of a filesystem volume according to its format policy, and returns
+// whether it did. A device holding another signature than the filesystem of the volume is refused
+// rather than mounted, unless the policy allows to format it.
+func formatDevice(b iscsiDiskMounter, devicePath, fsType, existingFormat string, readOnly bool) (bool, error) {
+	// a partitioned device is never formatted, whatever the policy
+	if existingFormat != "" && (existingFormat != fsType || b.formatPolicy == formatPolicyAlwaysOnCreate) {
+		ptType, err := getPartitionTableType(b.exec, devicePath)
+		if err != nil {
+			return false, err
+		}
+		if ptType != "" {
+			return false, fmt.Errorf("device %s of volume %s holds a %s partition table, refusing to format or mount it: set the partition attribute to mount one of its partitions", devicePath, b.VolName, ptType)
+		}
+	}
+
+	var fsUUID string
+	switch b.formatPolicy {
+	case formatPolicyAlwaysOnCreate:
+		fsUUID = volumeFilesystemUUID(b.VolName)
+		if existingFormat == fsType {
+			existingUUID, err := getFilesystemUUID(b.exec, devicePath)
+			if err != nil {
+				return false, err
+			}
+			if existingUUID == fsUUID {
+				return false, nil
+			}
+		}
+	case formatPolicyNever:
+		if existingFormat == "" {
+			return false, fmt.Errorf("device %s of volume %s holds no filesystem and formatPolicy is %s", devicePath, b.VolName, formatPolicyNever)
+		}
+		fallthrough
+	default:
+		if existingFormat == fsType {
+			return false, nil
+		}
+		if existingFormat != "" {
+			return false, fmt.Errorf("device %s of volume %s holds %s data instead of a %s filesystem, refusing to format or mount it: wipe the device or use formatPolicy %s",
+				devicePath, b.VolName, existingFormat, fsType, formatPolicyAlwaysOnCreate)
+		}
+	}
+
+	if readOnly {
+		return false, fmt.Errorf("cannot format device %s of volume %s read-only", devicePath, b.VolName)
+	}
+	args, err := mkfsArgs(fsType, b.mkfsOptions, fsUUID, devicePath)
+	if err != nil {
+		return false, err
+	}
+	if existingFormat != "" {
+		klog.Warningf("iscsi: wiping the %s signature of %s to format volume %s", existingFormat, devicePath, b.VolName)
+		if out, err := b.exec.Command("wipefs", "-a", devicePath).CombinedOutput(); err != nil {
+			return false, fmt.Errorf("wipefs of %s failed: %v, output: %s", devicePath, err, out)
+		}
+	}
+	klog.Infof("iscsi: formatting %s of volume %s with %s, options %v", devicePath, b.VolName, fsType, args)
+	if out, err := b.exec.Command("mkfs."+fsType, args...).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("mkfs.%s of %s failed: %v, output: %s", fsType, devicePath, err, out)
+	}
+	return true, nil
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	v1 "k8s.io/api/core/v1"
+	klog "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+const (
+	// fsckPolicyOff mounts the filesystems without checking them
+	fsckPolicyOff = "off"
+	// fsckPolicyCheck checks the filesystems without modifying them, and mounts them even if they hold errors
+	fsckPolicyCheck = "check"
+	// fsckPolicyRepair repairs the filesystems, and refuses to mount those that cannot be repaired
+	fsckPolicyRepair = "repair"
+)
+
+// results of the check of a filesystem
+const (
+	fsckClean    = "clean"
+	fsckErrors   = "errors"
+	fsckRepaired = "repaired"
+	fsckFailed   = "failed"
+	fsckSkipped  = "skipped"
+)
+
+// fsckResult is the result of the check of the filesystem of a volume, recorded while the volume is
+// published on the node to report it in its health
+type fsckResult struct {
+	Policy  string    `json:"policy"`
+	FSType  string    `json:"fsType"`
+	Result  string    `json:"result"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+func getFsckResultPath(volumeID string) string {
+	return fmt.Sprintf("/var/run/%s/fsck-%s.json", driverName, volumeID)
+}
+
+// parseFsckPolicy parses how the filesystem of a volume is checked before it is mounted, empty leaves
+// the check to the mount library
+func parseFsckPolicy(fsckPolicy string) (string, error) {
+	switch fsckPolicy {
+	case "", fsckPolicyOff, fsckPolicyCheck, fsckPolicyRepair:
+		return fsckPolicy, nil
+	}
+	return "", fmt.Errorf("invalid fsckPolicy %q: must be %q, %q or %q", fsckPolicy, fsckPolicyOff, fsckPolicyCheck, fsckPolicyRepair)
+}
+
+// loadFsckResult returns the result of the check of the filesystem of a volume, nil if it was not checked
+func loadFsckResult(volumeID string) (*fsckResult, error) {
+	data, err := os.ReadFile(getFsckResultPath(volumeID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result fsckResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse filesystem check of volume %s: %v", volumeID, err)
+	}
+	return &result, nil
+}
+
+// saveFsckResult records the result of the check of the filesystem of a volume
+func saveFsckResult(volumeID string, result *fsckResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getFsckResultPath(volumeID), data, 0o600)
+}
+
+// removeFsckResult removes the result of the check of the filesystem of a volume, it succeeds if
+// there is none
+func removeFsckResult(volumeID string) error {
+	if err := os.Remove(getFsckResultPath(volumeID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// fsckCommand returns the command checking or repairing a filesystem, nil if the filesystem has no
+// checker. btrfs is only checked, as its repair is not safe to run unattended.
+func fsckCommand(fsType, policy, device string) []string {
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		if policy == fsckPolicyRepair {
+			// preen only fixes the errors that are safe to fix without a human
+			return []string{"e2fsck", "-p", device}
+		}
+		return []string{"e2fsck", "-n", device}
+	case "xfs":
+		if policy == fsckPolicyRepair {
+			return []string{"xfs_repair", device}
+		}
+		return []string{"xfs_repair", "-n", device}
+	case "btrfs":
+		return []string{"btrfs", "check", "--readonly", device}
+	}
+	return nil
+}
+
+// fsckExitResult returns the result of the check of a filesystem from the exit status of its checker,
+// an empty string if the checker failed to run
+func fsckExitResult(fsType string, status int) string {
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		// the exit status of e2fsck is a bitmask
+		switch {
+		case status&^7 != 0:
+			return ""
+		case status&4 != 0:
+			return fsckErrors
+		case status&3 != 0:
+			return fsckRepaired
+		}
+	case "xfs":
+		switch status {
+		case 1:
+			return fsckErrors
+		case 2:
+			// the log of the filesystem is dirty, mounting the filesystem replays it
+			return fsckClean
+		}
+	case "btrfs":
+		if status == 1 {
+			return fsckErrors
+		}
+	}
+	return ""
+}
+
+// checkFilesystem checks or repairs the filesystem of a device according to the policy, within the
+// timeout
+func checkFilesystem(executor exec.Interface, device, fsType, policy string, timeout time.Duration) *fsckResult {
+	result := &fsckResult{Policy: policy, FSType: fsType, Time: time.Now()}
+	args := fsckCommand(fsType, policy, device)
+	if args == nil {
+		result.Result = fsckSkipped
+		result.Message = fmt.Sprintf("no checker for filesystem %s", fsType)
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := executor.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	output := lastLines(string(out), 5)
+	if err == nil {
+		result.Result = fsckClean
+		return result
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Result = fsckFailed
+		result.Message = fmt.Sprintf("%s timed out after %v", args[0], timeout)
+		return result
+	}
+	if exitErr, ok := err.(exec.ExitError); ok {
+		result.Result = fsckExitResult(fsType, exitErr.ExitStatus())
+	}
+	if result.Result == "" {
+		result.Result = fsckFailed
+		result.Message = fmt.Sprintf("%s failed: %v, output: %s", args[0], err, output)
+		return result
+	}
+	result.Message = output
+	return result
+}
+
+// lastLines returns the last lines of the output of a command
+func lastLines(out string, n int) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fsckHealth returns the health condition of a volume whose filesystem was checked, nil if it was clean
+func fsckHealth(result *fsckResult) *csi.VolumeHealth_VolumeHealthEntry {
+	checked := result.Time.UTC().Format(time.RFC3339)
+	switch result.Result {
+	case fsckErrors:
+		return &csi.VolumeHealth_VolumeHealthEntry{
+			Status:  csi.VolumeHealthErrorType_DEGRADED,
+			Reason:  "FilesystemErrors",
+			Message: fmt.Sprintf("%s filesystem checked at %s holds errors: %s", result.FSType, checked, result.Message),
+		}
+	case fsckRepaired:
+		return &csi.VolumeHealth_VolumeHealthEntry{
+			Status:  csi.VolumeHealthErrorType_DATA_LOSS,
+			Reason:  "FilesystemRepaired",
+			Message: fmt.Sprintf("%s filesystem was repaired at %s, damaged files may have been removed or moved to lost+found: %s", result.FSType, checked, result.Message),
+		}
+	case fsckFailed:
+		return &csi.VolumeHealth_VolumeHealthEntry{
+			Status:  csi.VolumeHealthErrorType_DEGRADED,
+			Reason:  "FilesystemCheckFailed",
+			Message: fmt.Sprintf("%s filesystem could not be checked at %s: %s", result.FSType, checked, result.Message),
+		}
+	}
+	return nil
+}
+
+// checkDeviceFilesystem checks the filesystem of the device of a volume before it is first mounted on
+// the node, records the result to report it in the health of the volume, and in the events of the
+// publication. The filesystems that cannot be repaired are refused.
+func checkDeviceFilesystem(b iscsiDiskMounter, devicePath, fsType string, readOnly bool) error {
+	policy := b.fsckPolicy
+	if readOnly && policy == fsckPolicyRepair {
+		// the read-only device cannot be repaired
+		policy = fsckPolicyCheck
+	}
+	result := checkFilesystem(b.exec, devicePath, fsType, policy, b.fsckTimeout)
+
+	eventType, reason := v1.EventTypeNormal, "FilesystemChecked"
+	switch result.Result {
+	case fsckErrors:
+		eventType, reason = v1.EventTypeWarning, "FilesystemErrors"
+	case fsckRepaired:
+		eventType, reason = v1.EventTypeWarning, "FilesystemRepaired"
+	case fsckFailed:
+		eventType, reason = v1.EventTypeWarning, "FilesystemCheckFailed"
+	case fsckSkipped:
+		reason = "FilesystemCheckSkipped"
+	}
+	message := fmt.Sprintf("%s of the %s filesystem of volume %s on %s: %s", policy, fsType, b.VolName, devicePath, result.Result)
+	if result.Message != "" {
+		message += ": " + result.Message
+	}
+	if eventType == v1.EventTypeWarning {
+		klog.Warningf("iscsi: %s", message)
+	} else {
+		klog.Infof("iscsi: %s", message)
+	}
+	if b.events != nil {
+		b.events.Event(b.eventObject, eventType, reason, message)
+	}
+
+	if policy == fsckPolicyRepair && (result.Result == fsckErrors || result.Result == fsckFailed) {
+		return fmt.Errorf("refusing to mount the filesystem that could not be repaired, %s", message)
+	}
+	return saveFsckResult(b.VolName, result)
+}
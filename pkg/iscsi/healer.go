@@ -0,0 +1,108 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+	"k8s.io/apimachinery/pkg/util/wait"
+	klog "k8s.io/klog/v2"
+)
+
+// pathHealer periodically walks the persisted connectors of the published volumes and logs in
+// again the paths of multipath volumes that are missing, so that their multipath device does not
+// stay degraded until the pod is rescheduled.
+type pathHealer struct {
+	driver *driver
+}
+
+func newPathHealer(d *driver) *pathHealer {
+	return &pathHealer{driver: d}
+}
+
+func (h *pathHealer) run(interval time.Duration, stopCh <-chan struct{}) {
+	klog.Infof("starting path healer with interval %v", interval)
+	wait.Until(h.healAll, interval, stopCh)
+}
+
+func (h *pathHealer) healAll() {
+	files, err := filepath.Glob(getIscsiInfoPath("*"))
+	if err != nil {
+		klog.Errorf("path healer: failed to list ISCSI connection info: %v", err)
+		return
+	}
+
+	missingPaths.Reset()
+	for _, file := range files {
+		volumeID := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "iscsi-"), ".json")
+		h.heal(volumeID)
+	}
+}
+
+func (h *pathHealer) heal(volumeID string) {
+	h.driver.volumeLocks.LockKey(volumeID)
+	defer func() { _ = h.driver.volumeLocks.UnlockKey(volumeID) }()
+	if err := h.driver.checkNoDetachInFlight(volumeID); err != nil {
+		klog.V(4).Infof("path healer: skipping volume %s: %v", volumeID, err)
+		return
+	}
+
+	iscsiInfoPath := getIscsiInfoPath(volumeID)
+	connector, err := iscsiLib.GetConnectorFromFile(iscsiInfoPath)
+	if err != nil {
+		klog.V(4).Infof("path healer: skipping volume %s, failed to load ISCSI connection info: %v", volumeID, err)
+		return
+	}
+	// a failover volume is logged in to its active portal only, logging in to the others would turn
+	// it into a multipath volume. A multipath volume attached through a single path may have no
+	// multipath device yet, it is healed too.
+	if connector.MountTargetDevice == nil || connector.PortalMode == iscsiLib.PortalModeFailover || len(connector.TargetPortals) < 2 {
+		return
+	}
+
+	mountTargetDevice := connector.MountTargetDevice.Name
+	healed, err := connector.HealPaths()
+	missingPaths.WithLabelValues(volumeID).Set(float64(len(connector.MissingPortals)))
+	mapped := connector.MountTargetDevice.Name != mountTargetDevice
+	if len(healed) == 0 && !mapped && err == nil {
+		return
+	}
+
+	if err != nil {
+		pathHealAttempts.WithLabelValues("failure").Inc()
+		klog.Warningf("path healer: failed to heal all paths of volume %s: %v", volumeID, err)
+	} else {
+		pathHealAttempts.WithLabelValues("success").Inc()
+	}
+	if len(healed) == 0 && !mapped {
+		return
+	}
+
+	if len(healed) > 0 {
+		pathsHealed.Add(float64(len(healed)))
+		klog.Infof("path healer: logged in again volume %s through portals %v", volumeID, healed)
+	}
+	if mapped {
+		klog.Infof("path healer: added multipath device %s of volume %s", connector.MountTargetDevice.Name, volumeID)
+	}
+	if err := connector.Persist(iscsiInfoPath); err != nil {
+		klog.Errorf("path healer: failed to persist ISCSI connection info of volume %s: %v", volumeID, err)
+	}
+}
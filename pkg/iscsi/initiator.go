@@ -0,0 +1,76 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	klog "k8s.io/klog/v2"
+)
+
+// errNoClient is returned by the lookups of node objects when the driver does not run in a cluster
+var errNoClient = errors.New("not running in a cluster")
+
+// initiatorNameAnnotation is the annotation of the node objects holding the initiator name of the
+// node, which the controller service grants access to the LUNs
+const initiatorNameAnnotation = driverName + "/initiator-name"
+
+// annotateInitiatorName records the initiator name of the node in its node object, nothing is done
+// when it is unknown or when the driver does not run in a cluster
+func (d *driver) annotateInitiatorName(ctx context.Context) error {
+	if d.initiatorName == "" {
+		return nil
+	}
+	if d.client == nil {
+		klog.V(2).Infof("not running in a cluster, the initiator name is not recorded")
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{initiatorNameAnnotation: d.initiatorName},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := d.client.CoreV1().Nodes().Patch(ctx, d.nodeID, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to record initiator name in node %s: %v", d.nodeID, err)
+	}
+	return nil
+}
+
+// nodeHost returns the host of a node, with the initiator name recorded in its node object, which is
+// empty if the node object is gone or does not have it
+func (d *driver) nodeHost(ctx context.Context, nodeID string) (backend.Host, error) {
+	host := backend.Host{NodeID: nodeID}
+	if d.client == nil {
+		return host, errNoClient
+	}
+	node, err := d.client.CoreV1().Nodes().Get(ctx, nodeID, metav1.GetOptions{})
+	if err != nil {
+		return host, err
+	}
+	host.InitiatorIQN = node.Annotations[initiatorNameAnnotation]
+	return host, nil
+}
@@ -21,24 +21,53 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/scsipr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/volume/util"
+	mount "k8s.io/mount-utils"
 	"k8s.io/utils/exec"
-	"k8s.io/utils/mount"
 )
 
+// keys of the CHAP credentials of the node in the publish context, the other keys of the publish
+// context are volume attributes
+const (
+	publishContextCHAPUser     = "chapUser"
+	publishContextCHAPPassword = "chapPassword"
+)
+
+// volumeAttributes returns the volume attributes of a volume, the values of the publish context set
+// by the controller service take precedence over the volume context
+func volumeAttributes(req *csi.NodePublishVolumeRequest) map[string]string {
+	attrs := map[string]string{}
+	for k, v := range req.GetVolumeContext() {
+		attrs[k] = v
+	}
+	if _, ok := req.GetPublishContext()["targetPortal"]; ok {
+		// the publish context describes the whole target, with or without other portals
+		delete(attrs, "portals")
+	}
+	for k, v := range req.GetPublishContext() {
+		attrs[k] = v
+	}
+	return attrs
+}
+
 func getISCSIInfo(req *csi.NodePublishVolumeRequest) (*iscsiDisk, error) {
+	attrs := volumeAttributes(req)
 	volName := req.GetVolumeId()
-	tp := req.GetVolumeContext()["targetPortal"]
-	iqn := req.GetVolumeContext()["iqn"]
-	lun := req.GetVolumeContext()["lun"]
+	tp := attrs["targetPortal"]
+	iqn := attrs["iqn"]
+	lun := attrs["lun"]
 	if tp == "" || iqn == "" || lun == "" {
 		return nil, fmt.Errorf("ISCSI target information is missing")
 	}
 
-	secretParams := req.GetVolumeContext()["secret"]
+	secretParams := attrs["secret"]
 	secret := parseSecret(secretParams)
 	sessionSecret, err := parseSessionSecret(secret)
 	if err != nil {
@@ -48,10 +77,14 @@ func getISCSIInfo(req *csi.NodePublishVolumeRequest) (*iscsiDisk, error) {
 	if err != nil {
 		return nil, err
 	}
+	if user, password := attrs[publishContextCHAPUser], attrs[publishContextCHAPPassword]; user != "" && password != "" {
+		// the node has its own CHAP credentials
+		sessionSecret = iscsiLib.Secrets{SecretsType: "chap", UserName: user, Password: password}
+	}
 
 	bkportal := []string{}
 
-	portalList := req.GetVolumeContext()["portals"]
+	portalList := attrs["portals"]
 	if len(portalList) > 0 {
 		portal := portalMounter(tp)
 		bkportal = append(bkportal, portal)
@@ -65,12 +98,39 @@ func getISCSIInfo(req *csi.NodePublishVolumeRequest) (*iscsiDisk, error) {
 		}
 	}
 
-	iface := req.GetVolumeContext()["iscsiInterface"]
-	initiatorName := req.GetVolumeContext()["initiatorName"]
-	chapDiscovery := req.GetVolumeContext()["discoveryCHAPAuth"] == "true"
-	chapSession := req.GetVolumeContext()["sessionCHAPAuth"] == "true"
+	iface := attrs["iscsiInterface"]
+	initiatorName := attrs["initiatorName"]
+	chapDiscovery := attrs["discoveryCHAPAuth"] == "true"
+	chapSession := attrs["sessionCHAPAuth"] == "true"
 
-	doDiscovery := req.GetVolumeContext()["discovery"] == "true"
+	doDiscovery := attrs["discovery"] == "true"
+	multipathdAdd := attrs["multipathdAdd"] == "true"
+	persistentReservation := attrs["persistentReservation"] == "true"
+	encrypted := attrs["encrypted"] == "true"
+	formatPolicy, err := parseFormatPolicy(attrs["formatPolicy"])
+	if err != nil {
+		return nil, err
+	}
+	mkfsOptions := strings.Fields(attrs["mkfsOptions"])
+	fsckPolicy, err := parseFsckPolicy(attrs["fsckPolicy"])
+	if err != nil {
+		return nil, err
+	}
+	partition, err := parsePartition(attrs["partition"])
+	if err != nil {
+		return nil, err
+	}
+	minPaths, err := parseMinPaths(attrs["minPaths"], len(bkportal))
+	if err != nil {
+		return nil, err
+	}
+	portalMode, portalOrder, err := parsePortalMode(attrs["portalMode"], attrs["portalOrder"])
+	if err != nil {
+		return nil, err
+	}
+	if portalMode == iscsiLib.PortalModeFailover && minPaths != 0 {
+		return nil, fmt.Errorf("minPaths cannot be used with portalMode %s", portalMode)
+	}
 
 	var lunVal int32
 	if lun != "" {
@@ -82,23 +142,38 @@ func getISCSIInfo(req *csi.NodePublishVolumeRequest) (*iscsiDisk, error) {
 	}
 
 	iscsiDisk := &iscsiDisk{
-		VolName:         volName,
-		Portals:         bkportal,
-		Iqn:             iqn,
-		lun:             lunVal,
-		Iface:           iface,
-		discovery:       doDiscovery,
-		chapDiscovery:   chapDiscovery,
-		chapSession:     chapSession,
-		secret:          secret,
-		sessionSecret:   sessionSecret,
-		discoverySecret: discoverySecret,
-		InitiatorName:   initiatorName,
+		VolName:               volName,
+		Portals:               bkportal,
+		Iqn:                   iqn,
+		lun:                   lunVal,
+		Iface:                 iface,
+		discovery:             doDiscovery,
+		chapDiscovery:         chapDiscovery,
+		chapSession:           chapSession,
+		secret:                secret,
+		sessionSecret:         sessionSecret,
+		discoverySecret:       discoverySecret,
+		InitiatorName:         initiatorName,
+		multipathdAdd:         multipathdAdd,
+		minPaths:              minPaths,
+		portalMode:            portalMode,
+		portalOrder:           portalOrder,
+		persistentReservation: persistentReservation,
+		encrypted:             encrypted,
+		formatPolicy:          formatPolicy,
+		mkfsOptions:           mkfsOptions,
+		fsckPolicy:            fsckPolicy,
+		partition:             partition,
 	}
 
 	return iscsiDisk, nil
 }
 
+// isReadOnlyMode reports whether the volumes with an access mode are only read
+func isReadOnlyMode(mode csi.VolumeCapability_AccessMode_Mode) bool {
+	return mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY || mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY
+}
+
 func buildISCSIConnector(iscsiInfo *iscsiDisk) *iscsiLib.Connector {
 	if iscsiInfo == nil || iscsiInfo.VolName == "" || iscsiInfo.Iqn == "" {
 		return nil
@@ -113,6 +188,10 @@ func buildISCSIConnector(iscsiInfo *iscsiDisk) *iscsiLib.Connector {
 		DiscoverySecrets: iscsiInfo.discoverySecret,
 		SessionSecrets:   iscsiInfo.sessionSecret,
 		Interface:        iscsiInfo.Iface,
+		MultipathdAdd:    iscsiInfo.multipathdAdd,
+		MinPaths:         iscsiInfo.minPaths,
+		PortalMode:       iscsiInfo.portalMode,
+		PortalOrder:      iscsiInfo.portalOrder,
 	}
 
 	if iscsiInfo.sessionSecret != (iscsiLib.Secrets{}) {
@@ -126,7 +205,7 @@ func buildISCSIConnector(iscsiInfo *iscsiDisk) *iscsiLib.Connector {
 }
 
 func getISCSIDiskMounter(iscsiInfo *iscsiDisk, req *csi.NodePublishVolumeRequest) *iscsiDiskMounter {
-	readOnly := req.GetReadonly()
+	readOnly := req.GetReadonly() || isReadOnlyMode(req.GetVolumeCapability().GetAccessMode().GetMode())
 	fsType := req.GetVolumeCapability().GetMount().GetFsType()
 	mountOptions := req.GetVolumeCapability().GetMount().GetMountFlags()
 
@@ -134,6 +213,7 @@ func getISCSIDiskMounter(iscsiInfo *iscsiDisk, req *csi.NodePublishVolumeRequest
 		iscsiDisk:    iscsiInfo,
 		fsType:       fsType,
 		readOnly:     readOnly,
+		block:        req.GetVolumeCapability().GetBlock() != nil,
 		mountOptions: mountOptions,
 		mounter:      &mount.SafeFormatAndMount{Interface: mount.New(""), Exec: exec.New()},
 		exec:         exec.New(),
@@ -172,6 +252,51 @@ func parseSecret(secretParams string) map[string]string {
 	return secret
 }
 
+// parseMinPaths parses the minimal number of paths required to attach a volume,
+// either a count or "all"
+func parseMinPaths(minPaths string, portals int) (int, error) {
+	switch minPaths {
+	case "":
+		return 0, nil
+	case "all":
+		return iscsiLib.MinPathsAll, nil
+	}
+
+	n, err := strconv.Atoi(minPaths)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid minPaths %q: must be a positive number or \"all\"", minPaths)
+	}
+	if n > portals {
+		return 0, fmt.Errorf("invalid minPaths %d: only %d portals are configured", n, portals)
+	}
+
+	return n, nil
+}
+
+// parsePortalMode parses how the portals of a volume are used and in which order they are tried
+func parsePortalMode(portalMode, portalOrder string) (string, string, error) {
+	switch portalMode {
+	case "":
+		portalMode = iscsiLib.PortalModeMultipath
+	case iscsiLib.PortalModeMultipath, iscsiLib.PortalModeFailover:
+	default:
+		return "", "", fmt.Errorf("invalid portalMode %q: must be %q or %q", portalMode, iscsiLib.PortalModeMultipath, iscsiLib.PortalModeFailover)
+	}
+
+	switch portalOrder {
+	case "":
+		portalOrder = iscsiLib.PortalOrderListed
+	case iscsiLib.PortalOrderListed, iscsiLib.PortalOrderReachability:
+	default:
+		return "", "", fmt.Errorf("invalid portalOrder %q: must be %q or %q", portalOrder, iscsiLib.PortalOrderListed, iscsiLib.PortalOrderReachability)
+	}
+	if portalOrder != iscsiLib.PortalOrderListed && portalMode != iscsiLib.PortalModeFailover {
+		return "", "", fmt.Errorf("portalOrder %s can only be used with portalMode %s", portalOrder, iscsiLib.PortalModeFailover)
+	}
+
+	return portalMode, portalOrder, nil
+}
+
 func parseSessionSecret(secretParams map[string]string) (iscsiLib.Secrets, error) {
 	var ok bool
 	secret := iscsiLib.Secrets{}
@@ -236,11 +361,30 @@ type iscsiDisk struct {
 	discoverySecret iscsiLib.Secrets
 	InitiatorName   string
 	VolName         string
+	multipathdAdd   bool
+	minPaths        int
+	portalMode      string
+	portalOrder     string
+	// persistentReservation fences the other nodes with a SCSI-3 persistent reservation
+	persistentReservation bool
+	// encrypted volumes are formatted with LUKS and used through a dm-crypt mapping
+	encrypted bool
+	// formatPolicy tells when the device of a filesystem volume is formatted
+	formatPolicy string
+	// mkfsOptions are passed to mkfs when the device is formatted
+	mkfsOptions []string
+	// fsckPolicy tells how the filesystem is checked before it is mounted, empty leaves it to the
+	// mount library
+	fsckPolicy string
+	// partition of the device holding the data of the volume, 0 for the whole device
+	partition int
 }
 
 type iscsiDiskMounter struct {
 	*iscsiDisk
-	readOnly     bool
+	readOnly bool
+	// block publishes the device itself instead of its filesystem
+	block        bool
 	fsType       string
 	mountOptions []string
 	mounter      *mount.SafeFormatAndMount
@@ -248,10 +392,21 @@ type iscsiDiskMounter struct {
 	deviceUtil   util.DeviceUtil
 	targetPath   string
 	connector    *iscsiLib.Connector
+	// reservationKey is the key the node reserves the LUN with, 0 to not reserve it
+	reservationKey uint64
+	reservations   scsipr.Interface
+	// passphrase of the LUKS header of an encrypted volume
+	passphrase string
+	// fsckTimeout bounds the check of the filesystem
+	fsckTimeout time.Duration
+	// events records the events of the publication on eventObject, nil to only log them
+	events      record.EventRecorder
+	eventObject *v1.ObjectReference
 }
 
 type iscsiDiskUnmounter struct {
 	*iscsiDisk
-	mounter mount.Interface
-	exec    exec.Interface
+	mounter      mount.Interface
+	exec         exec.Interface
+	reservations scsipr.Interface
 }
@@ -19,23 +19,54 @@ package iscsi
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/scsipr"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	klog "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
 
-	"k8s.io/utils/mount"
+	mount "k8s.io/mount-utils"
 )
 
+// forceDetachStepTimeout bounds each step of a forced detach
+const forceDetachStepTimeout = 10 * time.Second
+
 type ISCSIUtil struct{}
 
+// AttachDisk connects and mounts a volume. Publishing is a journaled sequence of steps, on failure
+// the completed steps are undone in reverse order so that the node is left as it was.
 func (util *ISCSIUtil) AttachDisk(b iscsiDiskMounter) (string, error) {
 	if b.connector == nil {
 		return "", fmt.Errorf("connector is nil")
 	}
 
-	devicePath, err := (*b.connector).Connect()
+	journal := &iscsiLib.Journal{}
+	devicePath, err := util.attachDisk(b, journal)
+	if err != nil {
+		klog.Errorf("iscsi: failed to attach volume %s, rolling back steps %v", b.VolName, journal.Steps())
+		if rollbackErr := journal.Rollback(); rollbackErr != nil {
+			klog.Errorf("iscsi: failed to roll back attach of volume %s: %v", b.VolName, rollbackErr)
+		}
+	}
+
+	return devicePath, err
+}
+
+func (util *ISCSIUtil) attachDisk(b iscsiDiskMounter, journal *iscsiLib.Journal) (string, error) {
+	publications, err := loadPublications(b.VolName)
+	if err != nil {
+		return "", err
+	}
+	others := withoutPublication(publications, b.targetPath)
+
+	devicePath, err := (*b.connector).ConnectWithJournal(journal)
 	if err != nil {
 		return "", err
 	}
@@ -53,18 +84,92 @@ func (util *ISCSIUtil) AttachDisk(b iscsiDiskMounter) (string, error) {
 		return "", nil
 	}
 
-	if err := os.MkdirAll(mntPath, 0o750); err != nil {
-		klog.Errorf("iscsi: failed to mkdir %s, error", mntPath)
-		return "", err
+	if os.IsNotExist(err) {
+		if err := makeTargetPath(mntPath, b.block); err != nil {
+			klog.Errorf("iscsi: failed to create %s, error", mntPath)
+			return "", err
+		}
+		journal.Record("target path "+mntPath, func() error {
+			return os.Remove(mntPath)
+		})
 	}
 
+	if b.partition != 0 {
+		partitionPath, err := waitForPartition(devicePath, b.partition, b.connector.RetryCount, b.connector.CheckInterval)
+		if err != nil {
+			return "", err
+		}
+		b.connector.PartitionDevice = partitionPath
+		devicePath = partitionPath
+	}
+	if b.encrypted {
+		// recorded before the mapping is opened, so that a detach after a crash closes it
+		b.connector.CryptMapping = cryptMappingName(b.VolName)
+	}
 	// Persist iscsi disk config to json file for DetachDisk path
 	iscsiInfoPath := getIscsiInfoPath(b.VolName)
+	previousInfo, err := os.ReadFile(iscsiInfoPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("unable to read persistence file for connection: %v", err)
+	}
 	err = iscsiLib.PersistConnector(b.connector, iscsiInfoPath)
 	if err != nil {
 		klog.Errorf("failed to persist connection info: %v, disconnecting volume and failing the publish request because persistence files are required for reliable Unpublish", err)
 		return "", fmt.Errorf("unable to create persistence file for connection")
 	}
+	journal.Record("persisted "+iscsiInfoPath, func() error {
+		if previousInfo != nil {
+			return os.WriteFile(iscsiInfoPath, previousInfo, 0o600)
+		}
+		return os.Remove(iscsiInfoPath)
+	})
+
+	// the devices are read-only while all the publications of the volume on the node are
+	wasReadOnly := allReadOnly(others)
+	readOnly := b.readOnly && (len(others) == 0 || wasReadOnly)
+	if b.encrypted && wasReadOnly && !readOnly && cryptMappingExists(b.connector.CryptMapping) {
+		return "", fmt.Errorf("encrypted volume %s is opened read-only by its other publications on this node", b.VolName)
+	}
+	if readOnly || wasReadOnly {
+		if err := setDevicesReadOnly(b.connector, readOnly); err != nil {
+			return "", err
+		}
+		journal.Record("read-only "+devicePath, func() error {
+			return setDevicesReadOnly(b.connector, wasReadOnly)
+		})
+	}
+
+	// the LUN is reserved before it is mounted, so that the node it was published on before cannot
+	// write to it anymore
+	if b.reservationKey != 0 {
+		paths := reservationPaths(b.connector)
+		preempted, err := scsipr.Acquire(b.reservations, paths, b.reservationKey)
+		if err != nil {
+			return "", err
+		}
+		if preempted != 0 {
+			klog.Warningf("iscsi: preempted the reservation of volume %s by key 0x%x, whose node can no longer write to it", b.VolName, preempted)
+		}
+		if reservationKey(others) == 0 {
+			journal.Record("reserved "+devicePath, func() error {
+				return scsipr.Release(b.reservations, paths, b.reservationKey)
+			})
+		}
+	}
+
+	if b.encrypted {
+		name := b.connector.CryptMapping
+		cryptDevice, opened, err := openEncryptedDevice(b.exec, devicePath, name, b.passphrase, readOnly)
+		if err != nil {
+			return "", err
+		}
+		if opened {
+			journal.Record("opened "+cryptDevice, func() error {
+				return luksClose(b.exec, name)
+			})
+		}
+		devicePath = cryptDevice
+	}
 
 	var options []string
 
@@ -73,47 +178,140 @@ func (util *ISCSIUtil) AttachDisk(b iscsiDiskMounter) (string, error) {
 	} else {
 		options = append(options, "rw")
 	}
-	options = append(options, b.mountOptions...)
 
-	err = b.mounter.FormatAndMount(devicePath, mntPath, b.fsType, options)
-	if err != nil {
-		klog.Errorf("iscsi: failed to mount iscsi volume %s [%s] to %s, error %v", devicePath, b.fsType, mntPath, err)
+	if b.block {
+		options = append(options, "bind")
+		if err := b.mounter.Mount(devicePath, mntPath, "", options); err != nil {
+			klog.Errorf("iscsi: failed to bind mount iscsi volume %s to %s, error %v", devicePath, mntPath, err)
+			return "", err
+		}
+	} else {
+		options = append(options, b.mountOptions...)
+
+		fsType := b.fsType
+		if fsType == "" {
+			fsType = defaultFSType
+		}
+		existingFormat, err := getDiskFormat(b.exec, devicePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to get disk format of %s: %v", devicePath, err)
+		}
+		formatted, err := formatDevice(b, devicePath, fsType, existingFormat, readOnly)
+		if err != nil {
+			return "", err
+		}
+		if formatted {
+			// a new filesystem holds no data and cannot be unformatted, there is nothing to undo
+			journal.Record("formatted "+devicePath, nil)
+		} else if readOnly {
+			// the journal cannot be replayed on the read-only device
+			options = append(options, noRecoveryOptions(existingFormat)...)
+		}
+
+		// the filesystem is checked once, before the device is first mounted on the node
+		if !formatted && len(others) == 0 && b.fsckPolicy != "" && b.fsckPolicy != fsckPolicyOff {
+			if err := checkDeviceFilesystem(b, devicePath, fsType, readOnly); err != nil {
+				return "", err
+			}
+			journal.Record("checked "+devicePath, func() error {
+				return removeFsckResult(b.VolName)
+			})
+		}
+
+		if b.fsckPolicy == "" {
+			// the device is formatted, FormatAndMount only checks the filesystem before mounting it
+			err = b.mounter.FormatAndMount(devicePath, mntPath, fsType, options)
+		} else {
+			err = b.mounter.Mount(devicePath, mntPath, fsType, options)
+		}
+		if err != nil {
+			klog.Errorf("iscsi: failed to mount iscsi volume %s [%s] to %s, error %v", devicePath, fsType, mntPath, err)
+			return "", err
+		}
 	}
+	journal.Record("mounted "+mntPath, func() error {
+		return b.mounter.Unmount(mntPath)
+	})
 
-	return devicePath, err
+	if err := savePublications(b.VolName, append(others, publication{TargetPath: mntPath, ReadOnly: b.readOnly, ReservationKey: b.reservationKey})); err != nil {
+		return "", fmt.Errorf("failed to record publication of volume %s: %v", b.VolName, err)
+	}
+	journal.Record("publication "+mntPath, func() error {
+		return savePublications(b.VolName, publications)
+	})
+
+	return devicePath, nil
 }
 
-func (util *ISCSIUtil) DetachDisk(c iscsiDiskUnmounter, targetPath string) error {
-	_, cnt, err := mount.GetDeviceNameFromMount(c.mounter, targetPath)
+// makeTargetPath creates the directory a filesystem volume is mounted on, or the file a block volume
+// is bind mounted on
+func makeTargetPath(targetPath string, block bool) error {
+	if !block {
+		return os.MkdirAll(targetPath, 0o750)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(targetPath, os.O_CREATE, 0o640)
 	if err != nil {
-		klog.Errorf("iscsi detach disk: failed to get device from mnt: %s\nError: %v", targetPath, err)
 		return err
 	}
-	if pathExists, pathErr := mount.PathExists(targetPath); pathErr != nil {
-		return fmt.Errorf("error checking if path exists: %v", pathErr)
-	} else if !pathExists {
-		klog.Warningf("warning: Unmount skipped because path does not exist: %v", targetPath)
-		return nil
+	return f.Close()
+}
+
+// DetachDisk unmounts and disconnects a volume. Each step is idempotent and skipped when already
+// done, so that a retry after a partial failure or a node reboot finishes the job from whatever state
+// is left.
+func (util *ISCSIUtil) DetachDisk(c iscsiDiskUnmounter, targetPath string) error {
+	if err := mount.CleanupMountPoint(targetPath, c.mounter, true); err != nil {
+		klog.Errorf("iscsi detach disk: failed to unmount: %s\nError: %v", targetPath, err)
+		return err
 	}
+
 	iscsiInfoPath := getIscsiInfoPath(c.VolName)
 	klog.Infof("loading ISCSI connection info from %s", iscsiInfoPath)
-	connector, err := iscsiLib.GetConnectorFromFile(iscsiInfoPath)
+	connector, err := iscsiLib.LoadRemainingConnectorFromFile(iscsiInfoPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			klog.Warningf("assuming that ISCSI connection is already closed")
-			return nil
+			if inUse, _, err := releasePublication(nil, c.VolName, targetPath); err != nil || inUse {
+				return err
+			}
+			return savePublications(c.VolName, nil)
 		}
 		return status.Error(codes.Internal, err.Error())
 	}
-	if err = c.mounter.Unmount(targetPath); err != nil {
-		klog.Errorf("iscsi detach disk: failed to unmount: %s\nError: %v", targetPath, err)
+
+	inUse, key, err := releasePublication(connector, c.VolName, targetPath)
+	if err != nil {
 		return err
 	}
-	cnt--
-	if cnt != 0 {
-		klog.Errorf("the device is in use : %d", cnt)
+	if inUse {
+		klog.Infof("volume %s is still published on other target paths", c.VolName)
 		return nil
 	}
+	if connector.MountTargetDevice != nil {
+		devicePath := mountedDevicePath(connector)
+		cnt, err := countDeviceMounts(c.mounter, devicePath)
+		if err != nil {
+			return err
+		}
+		if cnt != 0 {
+			klog.Infof("the device %s is in use: %d", devicePath, cnt)
+			return nil
+		}
+	}
+
+	if connector.CryptMapping != "" {
+		if err := luksClose(c.exec, connector.CryptMapping); err != nil {
+			return err
+		}
+	}
+	if key != 0 {
+		if err := scsipr.Release(c.reservations, reservationPaths(connector), key); err != nil {
+			return err
+		}
+	}
 
 	klog.Info("detaching ISCSI device")
 	err = connector.DisconnectVolume()
@@ -122,12 +320,11 @@ func (util *ISCSIUtil) DetachDisk(c iscsiDiskUnmounter, targetPath string) error
 		return err
 	}
 
-	iscsiLib.Disconnect(connector.TargetIqn, connector.TargetPortals)
-	if err := os.RemoveAll(targetPath); err != nil {
-		klog.Errorf("iscsi: failed to remove mount path Error: %v", err)
+	connector.Disconnect()
+	if err := os.Remove(iscsiInfoPath); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	err = os.Remove(iscsiInfoPath)
-	if err != nil {
+	if err := savePublications(c.VolName, nil); err != nil {
 		return err
 	}
 
@@ -136,8 +333,305 @@ func (util *ISCSIUtil) DetachDisk(c iscsiDiskUnmounter, targetPath string) error
 	return nil
 }
 
+// ForceDetachDisk detaches a volume whose target is unreachable without hanging: the I/O queued on its
+// multipath device is failed, the target path is lazily unmounted, and its devices are removed without
+// flushing their buffered writes. Every step gives up after forceDetachStepTimeout.
+func (util *ISCSIUtil) ForceDetachDisk(c iscsiDiskUnmounter, targetPath string) error {
+	klog.Warningf("iscsi: forcing detach of volume %s, writes that did not reach the target are discarded and data loss is possible", c.VolName)
+
+	iscsiInfoPath := getIscsiInfoPath(c.VolName)
+	var connector *iscsiLib.Connector
+	err := iscsiLib.RunWithTimeout("loading of ISCSI connection info", forceDetachStepTimeout, func() error {
+		var err error
+		connector, err = iscsiLib.LoadRemainingConnectorFromFile(iscsiInfoPath)
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if connector != nil {
+		if err := connector.FailQueuedIO(forceDetachStepTimeout); err != nil {
+			klog.Warningf("iscsi: failed to fail the queued I/O of volume %s: %v", c.VolName, err)
+		}
+	}
+	if err := lazyUnmount(c.exec, targetPath); err != nil {
+		return err
+	}
+	// the reservation of the LUN is not released, as the LUN is likely unreachable, the next node
+	// publishing the volume preempts it
+	inUse, _, err := releasePublication(connector, c.VolName, targetPath)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		klog.Infof("volume %s is still published on other target paths", c.VolName)
+		return nil
+	}
+	if connector == nil {
+		klog.Warningf("assuming that ISCSI connection is already closed")
+		return savePublications(c.VolName, nil)
+	}
+	if connector.MountTargetDevice != nil {
+		devicePath := mountedDevicePath(connector)
+		cnt, err := countDeviceMounts(c.mounter, devicePath)
+		if err != nil {
+			return err
+		}
+		if cnt != 0 {
+			klog.Infof("the device %s is in use: %d", devicePath, cnt)
+			return nil
+		}
+	}
+
+	if connector.CryptMapping != "" && cryptMappingExists(connector.CryptMapping) {
+		if err := forceRemoveMapping(c.exec, connector.CryptMapping); err != nil {
+			return err
+		}
+	}
+	if strings.HasPrefix(connector.PartitionDevice, "/dev/mapper/") {
+		// the kpartx mapping of the partition holds the multipath device open
+		if err := forceRemoveMapping(c.exec, filepath.Base(connector.PartitionDevice)); err != nil {
+			return err
+		}
+	}
+	if err := connector.ForceDisconnectVolume(forceDetachStepTimeout); err != nil {
+		return err
+	}
+	err = iscsiLib.RunWithTimeout("logout", forceDetachStepTimeout, func() error {
+		connector.Disconnect()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(iscsiInfoPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := savePublications(c.VolName, nil); err != nil {
+		return err
+	}
+
+	klog.Warningf("iscsi: forced detach of volume %s completed", c.VolName)
+	return nil
+}
+
+// forceRemoveMapping removes a device-mapper mapping, whose table is replaced with an error target if
+// it cannot be removed
+func forceRemoveMapping(executor exec.Interface, name string) error {
+	return iscsiLib.RunWithTimeout("removal of "+name, forceDetachStepTimeout, func() error {
+		out, err := executor.Command("dmsetup", "remove", "--force", name).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("dmsetup remove of %s failed: %v, output: %s", name, err, out)
+		}
+		return nil
+	})
+}
+
+// lazyUnmount detaches the filesystem mounted at the target path even if it is busy, and removes the
+// target path
+func lazyUnmount(executor exec.Interface, targetPath string) error {
+	return iscsiLib.RunWithTimeout("lazy unmount of "+targetPath, forceDetachStepTimeout, func() error {
+		out, err := executor.Command("umount", "-l", targetPath).CombinedOutput()
+		if err != nil && !strings.Contains(string(out), "not mounted") && !strings.Contains(string(out), "No such file or directory") {
+			return fmt.Errorf("lazy unmount of %s failed: %v, output: %s", targetPath, err, out)
+		}
+		if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// ExpandDisk rescans the devices of a published volume, so that they pick up the new size of its
+// LUN, grows the dm-crypt mapping of an encrypted volume, and grows its filesystem mounted on
+// targetPath unless it is a block volume. It returns the new size of the volume.
+func (util *ISCSIUtil) ExpandDisk(volumeID, targetPath string, block bool, passphrase string) (int64, error) {
+	connector, err := iscsiLib.GetConnectorFromFile(getIscsiInfoPath(volumeID))
+	if err != nil {
+		return 0, err
+	}
+	if err := connector.Resize(); err != nil {
+		return 0, err
+	}
+
+	executor := exec.New()
+	devicePath := connector.MountTargetDevice.GetPath()
+	if connector.PartitionDevice != "" {
+		// the partition is not grown, its filesystem can only grow within it
+		devicePath = connector.PartitionDevice
+	}
+	if connector.CryptMapping != "" {
+		if err := luksResize(executor, connector.CryptMapping, passphrase); err != nil {
+			return 0, err
+		}
+		devicePath = cryptMappingPath(connector.CryptMapping)
+	}
+	if block {
+		return getDeviceSize(executor, devicePath)
+	}
+	if _, err := mount.NewResizeFs(executor).Resize(devicePath, targetPath); err != nil {
+		return 0, fmt.Errorf("failed to resize filesystem of %s: %v", devicePath, err)
+	}
+	return getDeviceSize(executor, devicePath)
+}
+
+// noRecoveryOptions returns the mount options that skip the replay of the journal of a filesystem,
+// which writes to the device even when the filesystem is mounted read-only
+func noRecoveryOptions(fsType string) []string {
+	switch fsType {
+	case "ext3", "ext4":
+		return []string{"noload"}
+	case "xfs":
+		return []string{"norecovery"}
+	case "btrfs":
+		return []string{"nologreplay"}
+	}
+	return nil
+}
+
+// getDeviceSize returns the size of a block device
+func getDeviceSize(executor exec.Interface, device string) (int64, error) {
+	out, err := executor.Command("blockdev", "--getsize64", device).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("blockdev of %s failed: %v, output: %s", device, err, out)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// getDiskFormat returns the filesystem or partition table type of a device, or an empty string if it
+// holds neither, like SafeFormatAndMount.GetDiskFormat which only exists on Linux
+func getDiskFormat(executor exec.Interface, device string) (string, error) {
+	out, err := executor.Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", device).CombinedOutput()
+	if err != nil {
+		if exitErr, ok := err.(exec.ExitError); ok && exitErr.ExitStatus() == 2 {
+			// blkid found no signature
+			return "", nil
+		}
+		return "", fmt.Errorf("blkid of %s failed: %v, output: %s", device, err, out)
+	}
+
+	var fstype, pttype string
+	for _, line := range strings.Split(string(out), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "TYPE":
+			fstype = kv[1]
+		case "PTTYPE":
+			pttype = kv[1]
+		}
+	}
+	if fstype == "" {
+		return pttype, nil
+	}
+	return fstype, nil
+}
+
+// mountedDevicePath returns the device the filesystem of a volume is mounted from: the crypt mapping
+// of an encrypted volume, the partition of a partitioned one, or the device of its LUN
+func mountedDevicePath(connector *iscsiLib.Connector) string {
+	if connector.CryptMapping != "" {
+		return cryptMappingPath(connector.CryptMapping)
+	}
+	if connector.PartitionDevice != "" {
+		return connector.PartitionDevice
+	}
+	return connector.MountTargetDevice.GetPath()
+}
+
+// countDeviceMounts returns the number of mount points of a device. The devices are compared once
+// their symlinks are resolved, as the mount table lists /dev/mapper/<name> for the /dev/dm-N devices.
+func countDeviceMounts(mounter mount.Interface, devicePath string) (int, error) {
+	mps, err := mounter.List()
+	if err != nil {
+		return 0, err
+	}
+
+	devicePath = resolveDevicePath(devicePath)
+	cnt := 0
+	for _, mp := range mps {
+		if resolveDevicePath(mp.Device) == devicePath {
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+// resolveDevicePath returns the path of a device with its symlinks resolved, or the path itself if
+// it cannot be resolved, e.g. for the pseudo devices of the mount table
+func resolveDevicePath(devicePath string) string {
+	if resolved, err := filepath.EvalSymlinks(devicePath); err == nil {
+		return resolved
+	}
+	return devicePath
+}
+
 func getIscsiInfoPath(volumeID string) string {
 	runPath := fmt.Sprintf("/var/run/%s", driverName)
 
 	return fmt.Sprintf("%s/iscsi-%s.json", runPath, volumeID)
 }
+
+// getVolumeHealth reports the adverse health conditions of a published volume, including the result
+// of the check of its filesystem and the state of the reservation of its LUN if it was reserved
+func getVolumeHealth(volumeID string, reservations scsipr.Interface) *csi.VolumeHealth {
+	health := &csi.VolumeHealth{VolumeId: volumeID}
+
+	connector, err := iscsiLib.LoadConnectorFromFile(getIscsiInfoPath(volumeID))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Warningf("failed to load ISCSI connection info of volume %s: %v", volumeID, err)
+		}
+		return health
+	}
+
+	if len(connector.MissingPortals) > 0 {
+		health.HealthStatuses = append(health.HealthStatuses, &csi.VolumeHealth_VolumeHealthEntry{
+			Status:  csi.VolumeHealthErrorType_DEGRADED,
+			Reason:  "MissingPaths",
+			Message: fmt.Sprintf("volume is attached with missing portals: %s", strings.Join(connector.MissingPortals, ", ")),
+		})
+	}
+
+	fsck, err := loadFsckResult(volumeID)
+	if err != nil {
+		klog.Warningf("failed to load filesystem check of volume %s: %v", volumeID, err)
+	} else if fsck != nil {
+		if entry := fsckHealth(fsck); entry != nil {
+			health.HealthStatuses = append(health.HealthStatuses, entry)
+		}
+	}
+
+	publications, err := loadPublications(volumeID)
+	if err != nil {
+		klog.Warningf("failed to load publications of volume %s: %v", volumeID, err)
+		return health
+	}
+	key := reservationKey(publications)
+	paths := reservationPaths(connector)
+	if key == 0 || len(paths) == 0 {
+		return health
+	}
+	reservation, err := reservations.ReadReservation(paths[0])
+	switch {
+	case err != nil:
+		klog.Warningf("failed to read reservation of volume %s: %v", volumeID, err)
+	case reservation == nil:
+		health.HealthStatuses = append(health.HealthStatuses, &csi.VolumeHealth_VolumeHealthEntry{
+			Status:  csi.VolumeHealthErrorType_DEGRADED,
+			Reason:  "ReservationLost",
+			Message: "volume is not reserved anymore, other nodes can write to it",
+		})
+	case reservation.Key != key:
+		health.HealthStatuses = append(health.HealthStatuses, &csi.VolumeHealth_VolumeHealthEntry{
+			Status:  csi.VolumeHealthErrorType_INACCESSIBLE,
+			Reason:  "ReservationPreempted",
+			Message: fmt.Sprintf("volume is reserved by key 0x%x of another node, this node can no longer write to it", reservation.Key),
+		})
+	}
+
+	return health
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+	mount "k8s.io/mount-utils"
+)
+
+func TestMountedDevicePath(t *testing.T) {
+	device := &iscsiLib.Device{Name: "mpatha", Type: "mpath"}
+	tests := []struct {
+		name      string
+		connector *iscsiLib.Connector
+		expected  string
+	}{
+		{
+			name:      "LUN",
+			connector: &iscsiLib.Connector{MountTargetDevice: device},
+			expected:  "/dev/mapper/mpatha",
+		},
+		{
+			name:      "partition",
+			connector: &iscsiLib.Connector{MountTargetDevice: device, PartitionDevice: "/dev/mapper/mpatha1"},
+			expected:  "/dev/mapper/mpatha1",
+		},
+		{
+			name:      "encrypted partition",
+			connector: &iscsiLib.Connector{MountTargetDevice: device, PartitionDevice: "/dev/mapper/mpatha1", CryptMapping: "csi-iscsi-vol1"},
+			expected:  "/dev/mapper/csi-iscsi-vol1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if path := mountedDevicePath(test.connector); path != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, path)
+			}
+		})
+	}
+}
+
+func TestCountDeviceMounts(t *testing.T) {
+	dir := t.TempDir()
+	device := filepath.Join(dir, "dm-3")
+	link := filepath.Join(dir, "mpatha")
+	other := filepath.Join(dir, "dm-4")
+	for _, path := range []string{device, other} {
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(device, link); err != nil {
+		t.Fatal(err)
+	}
+	mounter := mount.NewFakeMounter([]mount.MountPoint{
+		{Device: link, Path: "/var/lib/kubelet/pods/1/volumes/vol1"},
+		{Device: device, Path: "/var/lib/kubelet/pods/2/volumes/vol1"},
+		{Device: other, Path: "/var/lib/kubelet/pods/3/volumes/vol2"},
+		{Device: "tmpfs", Path: "/run"},
+	})
+
+	for _, devicePath := range []string{device, link} {
+		cnt, err := countDeviceMounts(mounter, devicePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cnt != 2 {
+			t.Errorf("expected 2 mounts of %s, got %d", devicePath, cnt)
+		}
+	}
+}
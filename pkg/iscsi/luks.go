@@ -0,0 +1,136 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	klog "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+// encryptionPassphraseKey is the key of the LUKS passphrase of the encrypted volumes in the secrets
+// of the node publish requests
+const encryptionPassphraseKey = "encryptionPassphrase"
+
+// cryptMappingName returns the name of the dm-crypt mapping of an encrypted volume
+func cryptMappingName(volumeID string) string {
+	return "luks-" + volumeID
+}
+
+// cryptMappingPath returns the device of a dm-crypt mapping
+func cryptMappingPath(name string) string {
+	return filepath.Join("/dev/mapper", name)
+}
+
+// cryptMappingExists reports whether a dm-crypt mapping is open
+func cryptMappingExists(name string) bool {
+	_, err := os.Stat(cryptMappingPath(name))
+	return err == nil
+}
+
+// isLUKS reports whether a device holds a LUKS header
+func isLUKS(executor exec.Interface, device string) (bool, error) {
+	out, err := executor.Command("cryptsetup", "isLuks", device).CombinedOutput()
+	if err != nil {
+		if exitErr, ok := err.(exec.ExitError); ok && exitErr.ExitStatus() == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("cryptsetup isLuks of %s failed: %v, output: %s", device, err, out)
+	}
+	return true, nil
+}
+
+// luksFormat writes a LUKS2 header protected by the passphrase on a device
+func luksFormat(executor exec.Interface, device, passphrase string) error {
+	klog.Infof("iscsi: formatting %s with LUKS", device)
+	return runCryptsetup(executor, passphrase, "-q", "luksFormat", "--type", "luks2", "--key-file", "-", device)
+}
+
+// luksOpen opens the dm-crypt mapping of a LUKS device
+func luksOpen(executor exec.Interface, device, name, passphrase string, readOnly bool) error {
+	args := []string{"open", "--type", "luks", "--key-file", "-"}
+	if readOnly {
+		args = append(args, "--readonly")
+	}
+	return runCryptsetup(executor, passphrase, append(args, device, name)...)
+}
+
+// luksClose closes a dm-crypt mapping, it succeeds if the mapping is not open
+func luksClose(executor exec.Interface, name string) error {
+	if !cryptMappingExists(name) {
+		return nil
+	}
+	return runCryptsetup(executor, "", "close", name)
+}
+
+// luksResize grows a dm-crypt mapping to the size of its device
+func luksResize(executor exec.Interface, name, passphrase string) error {
+	if passphrase == "" {
+		return runCryptsetup(executor, "", "resize", name)
+	}
+	return runCryptsetup(executor, passphrase, "resize", "--key-file", "-", name)
+}
+
+// runCryptsetup runs cryptsetup, with the passphrase on its standard input if it is set
+func runCryptsetup(executor exec.Interface, passphrase string, args ...string) error {
+	cmd := executor.Command("cryptsetup", args...)
+	if passphrase != "" {
+		cmd.SetStdin(strings.NewReader(passphrase))
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cryptsetup %s failed: %v, output: %s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+// openEncryptedDevice opens the dm-crypt mapping of an encrypted volume, and returns its device and
+// whether it was opened by this call rather than by another publication. The device of the volume is
+// formatted with LUKS on first use, only if it holds no data.
+func openEncryptedDevice(executor exec.Interface, devicePath, name, passphrase string, readOnly bool) (string, bool, error) {
+	if cryptMappingExists(name) {
+		return cryptMappingPath(name), false, nil
+	}
+
+	luks, err := isLUKS(executor, devicePath)
+	if err != nil {
+		return "", false, err
+	}
+	if !luks {
+		format, err := getDiskFormat(executor, devicePath)
+		if err != nil {
+			return "", false, err
+		}
+		if format != "" {
+			return "", false, fmt.Errorf("device %s of the encrypted volume holds unencrypted data of type %s", devicePath, format)
+		}
+		if readOnly {
+			return "", false, fmt.Errorf("cannot format unencrypted device %s read-only", devicePath)
+		}
+		if err := luksFormat(executor, devicePath, passphrase); err != nil {
+			return "", false, err
+		}
+	}
+	if err := luksOpen(executor, devicePath, name, passphrase, readOnly); err != nil {
+		return "", false, err
+	}
+	return cryptMappingPath(name), true, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	klog "k8s.io/klog/v2"
+)
+
+const metricsNamespace = "iscsi_csi"
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	pathHealAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "path_heal_attempts_total",
+		Help:      "Number of attempts to heal the missing paths of a volume, by result.",
+	}, []string{"result"})
+	pathsHealed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "paths_healed_total",
+		Help:      "Number of paths logged in again by the path healer.",
+	})
+	missingPaths = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "volume_missing_paths",
+		Help:      "Number of paths of a published volume that are not logged in.",
+	}, []string{"volume_id"})
+	cloneProgress = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "volume_clone_progress_ratio",
+		Help:      "Fraction of a volume copied by a running host copy clone.",
+	}, []string{"volume_id"})
+	wipeProgress = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "volume_wipe_progress_ratio",
+		Help:      "Fraction of a deleted volume wiped by a running wipe.",
+	}, []string{"volume_id"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(pathHealAttempts, pathsHealed, missingPaths, cloneProgress, wipeProgress)
+}
+
+// serveMetrics exposes the driver metrics over http on the given address
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+
+	klog.Infof("serving metrics on address: %s", address)
+	if err := http.ListenAndServe(address, mux); err != nil {
+		klog.Errorf("failed to serve metrics: %v", err)
+	}
+}
@@ -18,10 +18,14 @@ package iscsi
 
 import (
 	"context"
+	"os"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/scsipr"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	klog "k8s.io/klog/v2"
 )
 
 type nodeServer struct {
@@ -39,12 +43,40 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 	if len(req.GetTargetPath()) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "targetPath not provided")
 	}
+	if err := ns.Driver.validateVolumeCapability(req.GetVolumeCapability()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ns.Driver.volumeLocks.LockKey(req.GetVolumeId())
+	defer func() { _ = ns.Driver.volumeLocks.UnlockKey(req.GetVolumeId()) }()
+	if err := ns.Driver.checkNoDetachInFlight(req.GetVolumeId()); err != nil {
+		return nil, err
+	}
 
 	iscsiInfo, err := getISCSIInfo(req)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 	diskMounter := getISCSIDiskMounter(iscsiInfo, req)
+	if iscsiInfo.persistentReservation {
+		if req.GetVolumeCapability().GetAccessMode().GetMode() == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+			return nil, status.Error(codes.InvalidArgument, "persistent reservations cannot fence volumes written by several nodes")
+		}
+		// the read-only publications do not need to fence the other nodes
+		if !diskMounter.readOnly {
+			diskMounter.reservationKey = scsipr.NodeKey(ns.Driver.nodeID)
+		}
+	}
+	diskMounter.reservations = ns.Driver.reservations
+	diskMounter.fsckTimeout = ns.Driver.fsckTimeout
+	diskMounter.events = ns.Driver.events
+	diskMounter.eventObject = eventObject(req.GetVolumeContext(), ns.Driver.nodeID)
+	if iscsiInfo.encrypted {
+		diskMounter.passphrase = req.GetSecrets()[encryptionPassphraseKey]
+		if diskMounter.passphrase == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "%s of the encrypted volume missing in the secrets", encryptionPassphraseKey)
+		}
+	}
 
 	util := &ISCSIUtil{}
 	if _, err := util.AttachDisk(*diskMounter); err != nil {
@@ -63,12 +95,39 @@ func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 		return nil, status.Error(codes.InvalidArgument, "Target path not provided")
 	}
 
+	ns.Driver.volumeLocks.LockKey(req.GetVolumeId())
+	defer func() { _ = ns.Driver.volumeLocks.UnlockKey(req.GetVolumeId()) }()
+
 	diskUnmounter := getISCSIDiskUnmounter(req)
+	diskUnmounter.reservations = ns.Driver.reservations
+
+	// The detach is forced when the driver is configured to, or when the volume could not be detached
+	// within the grace period, as its target is likely unreachable and a regular detach hangs. A detach
+	// given up on that still runs is likely hung flushing the multipath device, the forced detach
+	// releases it by failing the queued I/O.
+	force := ns.Driver.forceDetach || ns.Driver.detachInFlight(req.GetVolumeId())
+	var gracePeriod time.Duration
+	if !force && ns.Driver.forceDetachGracePeriod > 0 {
+		gracePeriod = ns.Driver.remainingDetachGracePeriod(req.GetVolumeId())
+		force = gracePeriod <= 0
+	}
 
 	iscsiutil := &ISCSIUtil{}
-	if err := iscsiutil.DetachDisk(*diskUnmounter, targetPath); err != nil {
+	var err error
+	switch {
+	case force:
+		err = iscsiutil.ForceDetachDisk(*diskUnmounter, targetPath)
+	case gracePeriod > 0:
+		err = ns.Driver.runDetach(req.GetVolumeId(), gracePeriod, func() error {
+			return iscsiutil.DetachDisk(*diskUnmounter, targetPath)
+		})
+	default:
+		err = iscsiutil.DetachDisk(*diskUnmounter, targetPath)
+	}
+	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	ns.Driver.clearUnpublishAttempt(req.GetVolumeId())
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
@@ -82,22 +141,28 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 }
 
 func (ns *nodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
-	return &csi.NodeGetInfoResponse{
+	// the controller service looks up the initiator name in the node object to grant it access to
+	// LUNs, it is recorded before the node is registered. A failure does not prevent the registration,
+	// the volumes cannot be published on the node until the initiator name is recorded.
+	if err := ns.Driver.annotateInitiatorName(ctx); err != nil {
+		klog.Warningf("failed to record the initiator name of node %s: %v", ns.Driver.nodeID, err)
+	}
+	resp := &csi.NodeGetInfoResponse{
 		NodeId: ns.Driver.nodeID,
-	}, nil
+	}
+	segments, err := ns.Driver.nodeTopology(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if segments != nil {
+		resp.AccessibleTopology = &csi.Topology{Segments: segments}
+	}
+	return resp, nil
 }
 
 func (ns *nodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
 	return &csi.NodeGetCapabilitiesResponse{
-		Capabilities: []*csi.NodeServiceCapability{
-			{
-				Type: &csi.NodeServiceCapability_Rpc{
-					Rpc: &csi.NodeServiceCapability_RPC{
-						Type: csi.NodeServiceCapability_RPC_UNKNOWN,
-					},
-				},
-			},
-		},
+		Capabilities: ns.Driver.nscap,
 	}, nil
 }
 
@@ -105,6 +170,42 @@ func (ns *nodeServer) NodeGetVolumeStats(ctx context.Context, in *csi.NodeGetVol
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
+func (ns *nodeServer) NodeGetVolumeHealth(ctx context.Context, req *csi.NodeGetVolumeHealthRequest) (*csi.NodeGetVolumeHealthResponse, error) {
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+
+	return &csi.NodeGetVolumeHealthResponse{
+		VolumeHealth: getVolumeHealth(req.GetVolumeId(), ns.Driver.reservations),
+	}, nil
+}
+
+// NodeExpandVolume picks up the new size of the LUN of a published volume and grows its filesystem
 func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	if len(req.GetVolumePath()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume path missing in request")
+	}
+
+	ns.Driver.volumeLocks.LockKey(volumeID)
+	defer func() { _ = ns.Driver.volumeLocks.UnlockKey(volumeID) }()
+	if err := ns.Driver.checkNoDetachInFlight(volumeID); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(getIscsiInfoPath(volumeID)); os.IsNotExist(err) {
+		return nil, status.Errorf(codes.NotFound, "volume %s is not published on this node", volumeID)
+	}
+	iscsiutil := &ISCSIUtil{}
+	capacity, err := iscsiutil.ExpandDisk(volumeID, req.GetVolumePath(), req.GetVolumeCapability().GetBlock() != nil, req.GetSecrets()[encryptionPassphraseKey])
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &csi.NodeExpandVolumeResponse{
+		CapacityBytes: capacity,
+	}, nil
 }
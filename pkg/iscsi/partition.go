@@ -0,0 +1,80 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+// parsePartition parses the partition of the device a volume is used through, 0 for the whole device
+func parsePartition(partition string) (int, error) {
+	if partition == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(partition)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid partition %q: must be a positive number", partition)
+	}
+	return n, nil
+}
+
+// partitionPaths returns the possible paths of a partition of a device: kpartx names the partitions
+// of the multipath devices <map>-partN or <map>pN depending on the distribution, and the kernel
+// names the partitions of the SCSI devices sdXN
+func partitionPaths(devicePath string, partition int) []string {
+	n := strconv.Itoa(partition)
+	return []string{devicePath + "-part" + n, devicePath + "p" + n, devicePath + n}
+}
+
+// waitForPartition waits for a partition of a device to appear, as udev creates it after the device,
+// and returns its path
+func waitForPartition(devicePath string, partition int, retryCount, checkInterval uint) (string, error) {
+	paths := partitionPaths(devicePath, partition)
+	for i := uint(0); ; i++ {
+		for _, path := range paths {
+			if _, err := os.Stat(path); err == nil {
+				klog.V(2).Infof("iscsi: found partition %d of %s at %s", partition, devicePath, path)
+				return path, nil
+			}
+		}
+		if i >= retryCount {
+			break
+		}
+		time.Sleep(time.Duration(checkInterval) * time.Second)
+	}
+	return "", fmt.Errorf("partition %d of %s did not appear, looked for %s", partition, devicePath, strings.Join(paths, ", "))
+}
+
+// getPartitionTableType returns the type of the partition table of a device, an empty string if it
+// holds none
+func getPartitionTableType(executor exec.Interface, device string) (string, error) {
+	out, err := executor.Command("blkid", "-p", "-s", "PTTYPE", "-o", "value", device).CombinedOutput()
+	if err != nil {
+		if exitErr, ok := err.(exec.ExitError); ok && exitErr.ExitStatus() == 2 {
+			return "", nil
+		}
+		return "", fmt.Errorf("blkid of %s failed: %v, output: %s", device, err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+	klog "k8s.io/klog/v2"
+)
+
+// publication is a target path a volume is published on. The publications of a volume on the node
+// share its devices, which are only disconnected once the last publication is removed, and are only
+// read-only while all the publications are.
+type publication struct {
+	TargetPath string `json:"targetPath"`
+	ReadOnly   bool   `json:"readOnly"`
+	// ReservationKey is the key of the persistent reservation of the LUN, 0 if it is not reserved
+	ReservationKey uint64 `json:"reservationKey,omitempty"`
+}
+
+func getPublicationsPath(volumeID string) string {
+	return fmt.Sprintf("/var/run/%s/publications-%s.json", driverName, volumeID)
+}
+
+// loadPublications returns the publications of a volume on the node
+func loadPublications(volumeID string) ([]publication, error) {
+	data, err := os.ReadFile(getPublicationsPath(volumeID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var publications []publication
+	if err := json.Unmarshal(data, &publications); err != nil {
+		return nil, fmt.Errorf("failed to parse publications of volume %s: %v", volumeID, err)
+	}
+	return publications, nil
+}
+
+// savePublications records the publications of a volume on the node, or removes the record and the
+// result of the check of its filesystem if there is none left. The record is replaced atomically, as
+// it is read without the lock of the volume, and the volume lock must be held.
+func savePublications(volumeID string, publications []publication) error {
+	path := getPublicationsPath(volumeID)
+	if len(publications) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return removeFsckResult(volumeID)
+	}
+	data, err := json.Marshal(publications)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// withoutPublication returns the publications on other target paths than the given one
+func withoutPublication(publications []publication, targetPath string) []publication {
+	others := []publication{}
+	for _, p := range publications {
+		if p.TargetPath != targetPath {
+			others = append(others, p)
+		}
+	}
+	return others
+}
+
+// allReadOnly reports whether all the publications are read-only, false if there is none
+func allReadOnly(publications []publication) bool {
+	for _, p := range publications {
+		if !p.ReadOnly {
+			return false
+		}
+	}
+	return len(publications) > 0
+}
+
+// reservationKey returns the key the publications reserved the LUN with, 0 if they did not
+func reservationKey(publications []publication) uint64 {
+	for _, p := range publications {
+		if p.ReservationKey != 0 {
+			return p.ReservationKey
+		}
+	}
+	return 0
+}
+
+// reservationPaths returns the devices of the paths to the LUN of a connector, through which the
+// key of the node is registered
+func reservationPaths(connector *iscsiLib.Connector) []string {
+	paths := []string{}
+	for i := range connector.Devices {
+		paths = append(paths, connector.Devices[i].GetPath())
+	}
+	if len(paths) == 0 && connector.MountTargetDevice != nil {
+		paths = append(paths, connector.MountTargetDevice.GetPath())
+	}
+	return paths
+}
+
+// setDevicesReadOnly makes the multipath device and every path of a connector read-only or
+// read-write, so that the read-only publications cannot write to the LUN through any of them
+func setDevicesReadOnly(connector *iscsiLib.Connector, readOnly bool) error {
+	devices := map[string]bool{}
+	if connector.MountTargetDevice != nil {
+		devices[connector.MountTargetDevice.GetPath()] = true
+	}
+	for i := range connector.Devices {
+		devices[connector.Devices[i].GetPath()] = true
+	}
+	if connector.PartitionDevice != "" {
+		devices[connector.PartitionDevice] = true
+	}
+	for device := range devices {
+		if err := setBlockDeviceReadOnly(device, readOnly); err != nil {
+			return err
+		}
+		klog.V(4).Infof("iscsi: set read-only flag of %s to %t", device, readOnly)
+	}
+	return nil
+}
+
+// releasePublication removes the publication of a volume on a target path, and returns whether the
+// devices of the volume are still used by its other publications, and otherwise the key to release
+// the reservation of its LUN with. The devices become read-only if the remaining publications all are.
+func releasePublication(connector *iscsiLib.Connector, volumeID, targetPath string) (bool, uint64, error) {
+	publications, err := loadPublications(volumeID)
+	if err != nil {
+		return false, 0, err
+	}
+	others := withoutPublication(publications, targetPath)
+	if len(others) == 0 {
+		// the record is removed once the devices are disconnected
+		return false, reservationKey(publications), nil
+	}
+	if connector != nil && allReadOnly(others) && !allReadOnly(publications) {
+		if err := setDevicesReadOnly(connector, true); err != nil {
+			return false, 0, err
+		}
+	}
+	return true, 0, savePublications(volumeID, others)
+}
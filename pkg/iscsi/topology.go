@@ -0,0 +1,132 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// topologyKeyPrefix is the prefix of the topology keys given without prefix
+	topologyKeyPrefix = "topology." + driverName + "/"
+	// portalGroupKeyPrefix is the prefix of the topology keys of the portal groups, whose value is
+	// whether the portals of the group are reachable from the node
+	portalGroupKeyPrefix = "portals." + topologyKeyPrefix
+)
+
+// topologyKey returns the topology key of a key given on the command line, adding topologyKeyPrefix
+// if it has no prefix
+func topologyKey(key string) string {
+	if strings.Contains(key, "/") {
+		return key
+	}
+	return topologyKeyPrefix + key
+}
+
+// validateTopology checks that the topology segments of the node are valid node labels, as the
+// kubelet adds them to the labels of the node
+func validateTopology(segments map[string]string) error {
+	for key, value := range segments {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid topology key %q: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid value %q of topology key %s: %s", value, key, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// nodeTopology returns the topology segments of the node: the segments given on the command line,
+// the topology labels of the node object and the reachability of the portal groups. It returns nil
+// if the node has no topology.
+func (d *driver) nodeTopology(ctx context.Context) (map[string]string, error) {
+	segments := map[string]string{}
+	for key, value := range d.topology {
+		segments[key] = value
+	}
+
+	if len(d.topologyNodeLabels) > 0 {
+		labels, err := d.nodeLabels(ctx, d.nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the labels of node %s: %v", d.nodeID, err)
+		}
+		for _, key := range d.topologyNodeLabels {
+			value, ok := labels[key]
+			if !ok {
+				klog.Warningf("node %s has no topology label %s", d.nodeID, key)
+				continue
+			}
+			segments[key] = value
+		}
+	}
+
+	groups := make([]string, 0, len(d.portalGroups))
+	for group := range d.portalGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		reachable := probePortals(d.portalGroups[group], d.portalProbeTimeout)
+		klog.V(2).Infof("portal group %s reachable: %t", group, reachable)
+		segments[portalGroupKeyPrefix+group] = strconv.FormatBool(reachable)
+	}
+
+	if len(segments) == 0 {
+		return nil, nil
+	}
+	if err := validateTopology(segments); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// nodeLabels returns the labels of a node object
+func (d *driver) nodeLabels(ctx context.Context, nodeName string) (map[string]string, error) {
+	if d.client == nil {
+		return nil, errNoClient
+	}
+	node, err := d.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return node.Labels, nil
+}
+
+// probePortals returns whether one of the portals accepts a TCP connection within the timeout
+func probePortals(portals []string, timeout time.Duration) bool {
+	for _, portal := range portals {
+		conn, err := net.DialTimeout("tcp", portalMounter(portal), timeout)
+		if err != nil {
+			klog.V(4).Infof("portal %s is not reachable: %v", portal, err)
+			continue
+		}
+		_ = conn.Close()
+		return true
+	}
+	return false
+}
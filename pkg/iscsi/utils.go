@@ -19,6 +19,7 @@ package iscsi
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -49,6 +50,31 @@ func NewControllerServiceCapability(cap csi.ControllerServiceCapability_RPC_Type
 	}
 }
 
+func NewNodeServiceCapability(cap csi.NodeServiceCapability_RPC_Type) *csi.NodeServiceCapability {
+	return &csi.NodeServiceCapability{
+		Type: &csi.NodeServiceCapability_Rpc{
+			Rpc: &csi.NodeServiceCapability_RPC{
+				Type: cap,
+			},
+		},
+	}
+}
+
+// readInitiatorName reads the initiator name from an open-iscsi initiatorname.iscsi file
+func readInitiatorName(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(line, "InitiatorName="); ok && name != "" {
+			return strings.TrimSpace(name), nil
+		}
+	}
+	return "", fmt.Errorf("no InitiatorName in %s", path)
+}
+
 func ParseEndpoint(ep string) (string, string, error) {
 	if strings.HasPrefix(strings.ToLower(ep), "unix://") || strings.HasPrefix(strings.ToLower(ep), "tcp://") {
 		s := strings.SplitN(ep, "://", 2)
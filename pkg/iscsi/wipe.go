@@ -0,0 +1,182 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	klog "k8s.io/klog/v2"
+)
+
+// hostWiper wipes the LUNs of the deleted volumes through the host of the controller, before the
+// backend deletes them or returns them to their pool, so that their data does not leak to the next
+// volumes. Like a copy of the host cloner, a wipe runs in the background as it outlasts the
+// DeleteVolume calls, which return Aborted until it is complete, and its progress is checkpointed in
+// a file per volume, so that it resumes after a failure or a restart of the controller. The
+// checkpoint of a volume created with a wipePolicy parameter is written on creation to record it.
+type hostWiper struct {
+	driver *driver
+	// mode is the wipe policy of the volumes without wipePolicy parameter
+	mode lunio.WipeMode
+
+	lock sync.Mutex
+	// running and failed wipes, by volume
+	jobs map[string]*wipeJob
+}
+
+type wipeJob struct {
+	mode     lunio.WipeMode
+	progress lunio.Progress
+	cancel   context.CancelFunc
+	// closed when the wipe ends, with err set if it failed
+	done chan struct{}
+	err  error
+}
+
+// wipeCheckpoint is the persisted progress of a wipe
+type wipeCheckpoint struct {
+	// Mode is the wipe policy of the volume, empty to not wipe it
+	Mode lunio.WipeMode `json:"mode"`
+	lunio.Progress
+	Done bool `json:"done"`
+}
+
+func newHostWiper(d *driver, mode lunio.WipeMode) *hostWiper {
+	return &hostWiper{driver: d, mode: mode, jobs: map[string]*wipeJob{}}
+}
+
+// setPolicy records the wipe policy of a volume created with a wipePolicy parameter
+func (w *hostWiper) setPolicy(volumeID string, mode lunio.WipeMode) error {
+	checkpoint := &wipeCheckpoint{}
+	found, err := readCheckpointFile(w.checkpointPath(volumeID), checkpoint)
+	if err != nil || (found && checkpoint.Mode == mode) {
+		return err
+	}
+	return writeCheckpointFile(w.checkpointPath(volumeID), &wipeCheckpoint{Mode: mode})
+}
+
+// wipe returns nil once the LUN of a volume is wiped, if its wipe policy is none, or if it does not
+// exist anymore, and starts or resumes the wipe otherwise
+func (w *hostWiper) wipe(ctx context.Context, volumeID string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if job, ok := w.jobs[volumeID]; ok {
+		select {
+		case <-job.done:
+			delete(w.jobs, volumeID)
+			if job.err != nil {
+				// the next attempt resumes from the last checkpoint
+				return status.Errorf(codes.Internal, "failed to wipe volume %s: %v", volumeID, job.err)
+			}
+			return nil
+		default:
+			return status.Errorf(codes.Aborted, "wipe of volume %s in progress: %d/%d bytes", volumeID, job.progress.Offset, job.progress.Size)
+		}
+	}
+
+	checkpoint := &wipeCheckpoint{}
+	found, err := readCheckpointFile(w.checkpointPath(volumeID), checkpoint)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if !found {
+		checkpoint = &wipeCheckpoint{Mode: w.mode}
+	}
+	if checkpoint.Mode == "" || checkpoint.Done {
+		return nil
+	}
+
+	lun, err := w.driver.backend.GetLUN(ctx, volumeID)
+	if errors.Is(err, backend.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return backendError(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &wipeJob{mode: checkpoint.Mode, progress: checkpoint.Progress, cancel: cancel, done: make(chan struct{})}
+	w.jobs[volumeID] = job
+	go w.run(ctx, job, lun)
+
+	return status.Errorf(codes.Aborted, "wipe of volume %s started at offset %d", volumeID, checkpoint.Offset)
+}
+
+// cancel stops the wipe of a volume and removes its checkpoint, once its LUN is deleted
+func (w *hostWiper) cancel(volumeID string) error {
+	w.lock.Lock()
+	job, ok := w.jobs[volumeID]
+	delete(w.jobs, volumeID)
+	w.lock.Unlock()
+
+	if ok {
+		job.cancel()
+		<-job.done
+	}
+	if err := os.Remove(w.checkpointPath(volumeID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (w *hostWiper) run(ctx context.Context, job *wipeJob, lun *backend.LUN) {
+	defer close(job.done)
+	defer job.cancel()
+	defer wipeProgress.DeleteLabelValues(lun.ID)
+
+	job.err = w.wipeLUN(ctx, job, lun)
+	if job.err != nil {
+		klog.Errorf("failed to wipe volume %s: %v", lun.ID, job.err)
+		return
+	}
+	job.err = writeCheckpointFile(w.checkpointPath(lun.ID), &wipeCheckpoint{Mode: job.mode, Progress: job.progress, Done: true})
+}
+
+func (w *hostWiper) wipeLUN(ctx context.Context, job *wipeJob, lun *backend.LUN) (err error) {
+	access, err := w.driver.grantHostAccess(ctx, lun)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, w.driver.revokeHostAccess(lun)) }()
+
+	name := fmt.Sprintf("wipe-%s", lun.ID)
+	return lunio.WipeLUN(ctx, name, access, job.mode, job.progress, func(progress lunio.Progress) error {
+		if err := writeCheckpointFile(w.checkpointPath(lun.ID), &wipeCheckpoint{Mode: job.mode, Progress: progress}); err != nil {
+			return err
+		}
+		w.lock.Lock()
+		job.progress = progress
+		w.lock.Unlock()
+		wipeProgress.WithLabelValues(lun.ID).Set(float64(progress.Offset) / float64(progress.Size))
+		klog.V(4).Infof("wipe of volume %s: %d/%d bytes", lun.ID, progress.Offset, progress.Size)
+		return nil
+	})
+}
+
+func (w *hostWiper) checkpointPath(volumeID string) string {
+	return filepath.Join(w.driver.wipeCheckpointDir, volumeID+".json")
+}
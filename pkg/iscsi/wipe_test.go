@@ -0,0 +1,107 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsi
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/lunio"
+)
+
+func TestWipePolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		// defaultMode is the policy of the volumes without wipePolicy parameter
+		defaultMode lunio.WipeMode
+		// volumeMode is the wipePolicy parameter of the volume, nil without parameter
+		volumeMode *lunio.WipeMode
+	}{
+		{name: "default none"},
+		{name: "none parameter overrides the default", defaultMode: lunio.WipeZero, volumeMode: new(lunio.WipeMode)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the backend is not used, as there is nothing to wipe
+			d := &driver{wipeCheckpointDir: t.TempDir()}
+			w := newHostWiper(d, test.defaultMode)
+			if test.volumeMode != nil {
+				if err := w.setPolicy("vol1", *test.volumeMode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.wipe(context.Background(), "vol1"); err != nil {
+				t.Errorf("expected no wipe, got %v", err)
+			}
+			if err := w.cancel("vol1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(w.checkpointPath("vol1")); !os.IsNotExist(err) {
+				t.Errorf("expected the checkpoint to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSetWipePolicyKeepsProgress(t *testing.T) {
+	w := newHostWiper(&driver{wipeCheckpointDir: t.TempDir()}, "")
+	progress := &wipeCheckpoint{Mode: lunio.WipeZero, Progress: lunio.Progress{Offset: 1 << 20, Size: 1 << 30}}
+	if err := writeCheckpointFile(w.checkpointPath("vol1"), progress); err != nil {
+		t.Fatal(err)
+	}
+
+	// a retry of the creation of the volume records the same policy again
+	if err := w.setPolicy("vol1", lunio.WipeZero); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := &wipeCheckpoint{}
+	if _, err := readCheckpointFile(w.checkpointPath("vol1"), checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if *checkpoint != *progress {
+		t.Errorf("expected checkpoint %+v, got %+v", progress, checkpoint)
+	}
+}
+
+func TestCancelWipe(t *testing.T) {
+	w := newHostWiper(&driver{wipeCheckpointDir: t.TempDir()}, lunio.WipeZero)
+	if err := w.setPolicy("vol1", lunio.WipeZero); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &wipeJob{mode: lunio.WipeZero, cancel: cancel, done: make(chan struct{})}
+	w.jobs["vol1"] = job
+	go func() {
+		defer close(job.done)
+		<-ctx.Done()
+		job.err = ctx.Err()
+	}()
+
+	if err := w.cancel("vol1"); err != nil {
+		t.Fatal(err)
+	}
+	if job.err == nil {
+		t.Errorf("expected the wipe to be cancelled")
+	}
+	if len(w.jobs) > 0 {
+		t.Errorf("expected no wipe left, got %v", w.jobs)
+	}
+	if _, err := os.Stat(w.checkpointPath("vol1")); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed, got %v", err)
+	}
+}
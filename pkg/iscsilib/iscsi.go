@@ -20,10 +20,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -34,6 +37,25 @@ import (
 
 const defaultPort = "3260"
 
+// MinPathsAll requires every portal of a connector to have a path up
+const MinPathsAll = -1
+
+const (
+	// PortalModeMultipath logs in to every portal and mounts the multipath device of the LUN
+	PortalModeMultipath = "multipath"
+	// PortalModeFailover logs in to a single portal, the first one that accepts the login
+	PortalModeFailover = "failover"
+)
+
+const (
+	// PortalOrderListed tries the portals in the order they are configured
+	PortalOrderListed = "listed"
+	// PortalOrderReachability tries the portals from the fastest to answer to the slowest
+	PortalOrderReachability = "reachability"
+)
+
+const portalProbeTimeout = 2 * time.Second
+
 var (
 	execCommand        = exec.Command
 	execCommandContext = exec.CommandContext
@@ -88,6 +110,23 @@ type Connector struct {
 	CheckInterval     uint     `json:"check_interval"`
 	DoDiscovery       bool     `json:"do_discovery"`
 	DoCHAPDiscovery   bool     `json:"do_chap_discovery"`
+	MultipathdAdd     bool     `json:"multipathd_add"`
+	MinPaths          int      `json:"min_paths"`
+	MissingPortals    []string `json:"missing_portals"`
+	PortalMode        string   `json:"portal_mode"`
+	PortalOrder       string   `json:"portal_order"`
+	ActivePortal      string   `json:"active_portal"`
+	// CryptMapping is the dm-crypt mapping opened on the device of the volume, which must be closed
+	// before the device is disconnected
+	CryptMapping string `json:"crypt_mapping,omitempty"`
+	// PartitionDevice is the partition of the device the volume is used through, empty if the volume
+	// uses the whole device
+	PartitionDevice string `json:"partition_device,omitempty"`
+
+	// journal records the steps of the ongoing connection
+	journal *Journal
+	// createdDevices counts the devices that appeared during the ongoing connection
+	createdDevices int
 }
 
 // parseSession takes the raw stdout from the `iscsiadm -m session` command and encodes it into an iSCSI session type
@@ -245,8 +284,28 @@ func Connect(c Connector) (string, error) {
 	return c.Connect()
 }
 
-// Connect attempts to connect a volume to this node using the provided Connector info
+// Connect attempts to connect a volume to this node using the provided Connector info.
+// On failure, the steps already completed are undone so that the node is left as it was.
 func (c *Connector) Connect() (string, error) {
+	journal := &Journal{}
+	devicePath, err := c.ConnectWithJournal(journal)
+	if err != nil {
+		if rollbackErr := journal.Rollback(); rollbackErr != nil {
+			klog.V(2).Infof("Failed to roll back connection: %v", rollbackErr)
+		}
+	}
+
+	return devicePath, err
+}
+
+// ConnectWithJournal attempts to connect a volume to this node using the provided Connector info,
+// and records the completed steps in the journal. On failure, the caller is responsible for rolling
+// back the journal.
+func (c *Connector) ConnectWithJournal(journal *Journal) (string, error) {
+	c.journal = journal
+	c.createdDevices = 0
+	defer func() { c.journal = nil }()
+
 	if c.RetryCount == 0 {
 		c.RetryCount = 10
 	}
@@ -254,30 +313,37 @@ func (c *Connector) Connect() (string, error) {
 		c.CheckInterval = 1
 	}
 
-	iFace := "default"
-	if c.Interface != "" {
-		iFace = c.Interface
-	}
-
-	// make sure our iface exists and extract the transport type
-	out, err := ShowInterface(iFace)
+	iFace, iscsiTransport, err := c.getInterfaceTransport()
 	if err != nil {
 		return "", err
 	}
-	iscsiTransport := extractTransportName(out)
+
+	if c.PortalMode == PortalModeFailover {
+		return c.connectFailover(iFace, iscsiTransport)
+	}
 
 	var lastErr error
 	var devicePaths []string
+	c.MissingPortals = nil
 	for _, target := range c.TargetPortals {
 		devicePath, err := c.connectTarget(c.TargetIqn, target, iFace, iscsiTransport)
 		if err != nil {
 			lastErr = err
+			klog.V(2).Infof("Could not connect to portal %s: %v", target, err)
+			c.MissingPortals = append(c.MissingPortals, target)
 		} else {
 			klog.V(2).Infof("Appending device path: %s", devicePath)
 			devicePaths = append(devicePaths, devicePath)
 		}
 	}
 
+	if minPaths := c.requiredPaths(); len(devicePaths) > 0 && len(devicePaths) < minPaths {
+		return "", fmt.Errorf("only %d paths are up while %d are required, missing portals: %v, last error seen: %v", len(devicePaths), minPaths, c.MissingPortals, lastErr)
+	}
+	if len(c.MissingPortals) > 0 && len(devicePaths) > 0 {
+		klog.Warningf("Volume %s is attached in degraded mode, missing portals: %v", c.VolumeName, c.MissingPortals)
+	}
+
 	// GetISCSIDevices returns all devices if no paths are given
 	if len(devicePaths) < 1 {
 		c.Devices = []Device{}
@@ -294,16 +360,20 @@ func (c *Connector) Connect() (string, error) {
 	c.MountTargetDevice = mountTargetDevice
 	if err != nil {
 		klog.V(2).Infof("Connect failed: %v", err)
-		err := RemoveSCSIDevices(c.Devices...)
-		if err != nil {
-			return "", err
-		}
 		c.MountTargetDevice = nil
 		c.Devices = []Device{}
 		return "", err
 	}
 
 	if c.IsMultipathEnabled() {
+		// a multipath device whose paths all appeared during this connection was created by it
+		if c.createdDevices == len(c.Devices) {
+			multipathDevice := *c.MountTargetDevice
+			c.record("multipath device "+multipathDevice.Name, func() error {
+				return FlushMultipathDevice(&multipathDevice)
+			})
+		}
+
 		if err := c.IsMultipathConsistent(); err != nil {
 			return "", fmt.Errorf("multipath is inconsistent: %v", err)
 		}
@@ -312,6 +382,227 @@ func (c *Connector) Connect() (string, error) {
 	return c.MountTargetDevice.GetPath(), nil
 }
 
+// connectFailover logs in to the portals one after the other until one of them provides the device
+// of the LUN, and keeps a single session to the target
+func (c *Connector) connectFailover(iFace string, iscsiTransport string) (string, error) {
+	portals := c.TargetPortals
+	if c.PortalOrder == PortalOrderReachability {
+		portals = orderPortalsByReachability(portals)
+	}
+
+	var lastErr error
+	c.MissingPortals = nil
+	for _, target := range portals {
+		mark := c.journal.Mark()
+		devicePath, err := c.connectTarget(c.TargetIqn, target, iFace, iscsiTransport)
+		if err != nil {
+			klog.V(2).Infof("Could not connect to portal %s, failing over to the next portal: %v", target, err)
+			lastErr = err
+			_ = c.journal.RollbackTo(mark)
+			continue
+		}
+
+		devices, err := GetISCSIDevices([]string{devicePath}, true)
+		if err == nil && len(devices) == 0 {
+			err = fmt.Errorf("device %s not found", devicePath)
+		}
+		if err != nil {
+			klog.V(2).Infof("Could not find device of portal %s, failing over to the next portal: %v", target, err)
+			lastErr = err
+			_ = c.journal.RollbackTo(mark)
+			continue
+		}
+
+		c.ActivePortal = target
+		c.Devices = devices
+		if c.MountTargetDevice, err = c.getMountTargetDevice(); err != nil {
+			return "", err
+		}
+		klog.V(2).Infof("Connected volume %s through portal %s", c.VolumeName, target)
+		return c.MountTargetDevice.GetPath(), nil
+	}
+
+	c.Devices = []Device{}
+	return "", fmt.Errorf("failed to connect to any of the portals %v, last error seen: %v", portals, lastErr)
+}
+
+// orderPortalsByReachability sorts the portals by the time they take to accept a TCP connection,
+// unreachable portals are moved last
+func orderPortalsByReachability(portals []string) []string {
+	rtts := map[string]time.Duration{}
+	for _, portal := range portals {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", portalWithPort(portal), portalProbeTimeout)
+		if err != nil {
+			klog.V(2).Infof("Portal %s is not reachable: %v", portal, err)
+			rtts[portal] = math.MaxInt64
+			continue
+		}
+		rtts[portal] = time.Since(start)
+		_ = conn.Close()
+	}
+
+	ordered := append([]string{}, portals...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return rtts[ordered[i]] < rtts[ordered[j]]
+	})
+	klog.V(2).Infof("Portals ordered by reachability: %v", ordered)
+	return ordered
+}
+
+// getInterfaceTransport returns the iscsi interface of the connector and its transport type
+func (c *Connector) getInterfaceTransport() (string, string, error) {
+	iFace := "default"
+	if c.Interface != "" {
+		iFace = c.Interface
+	}
+
+	// make sure our iface exists and extract the transport type
+	out, err := ShowInterface(iFace)
+	if err != nil {
+		return "", "", err
+	}
+
+	return iFace, extractTransportName(out), nil
+}
+
+// HealPaths logs in again to the portals of the connector that have no session, either because they
+// were unreachable at attach time or because their session was dropped, and adds the new paths to the
+// multipath device of the connector. A connector attached through a single path has no multipath
+// device when multipathd only creates the maps of the WWIDs with several paths (find_multipaths):
+// its map is added once a second path is up. It returns the portals that were healed.
+func (c *Connector) HealPaths() ([]string, error) {
+	if c.RetryCount == 0 {
+		c.RetryCount = 10
+	}
+	if c.CheckInterval == 0 {
+		c.CheckInterval = 1
+	}
+	if c.MountTargetDevice == nil {
+		return nil, fmt.Errorf("paths cannot be healed without mount target device")
+	}
+	if c.PortalMode == PortalModeFailover {
+		return nil, fmt.Errorf("paths cannot be healed in %s portal mode, which logs in to a single portal", PortalModeFailover)
+	}
+
+	iFace, iscsiTransport, err := c.getInterfaceTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	missingPortals := map[string]bool{}
+	for _, portal := range c.MissingPortals {
+		missingPortals[portal] = true
+	}
+
+	c.journal = &Journal{}
+	defer func() { c.journal = nil }()
+
+	var lastErr error
+	var healed, missing []string
+	for _, target := range c.TargetPortals {
+		exists, err := sessionExists(portalWithPort(target), c.TargetIqn)
+		if err != nil {
+			return nil, err
+		}
+		if exists && !missingPortals[target] {
+			continue
+		}
+
+		klog.V(2).Infof("Healing path of volume %s through portal %s", c.VolumeName, target)
+		mark := c.journal.Mark()
+		devicePath, err := c.connectTarget(c.TargetIqn, target, iFace, iscsiTransport)
+		if err == nil {
+			err = c.addPath(devicePath)
+		}
+		if err != nil {
+			klog.V(2).Infof("Could not heal path through portal %s: %v", target, err)
+			_ = c.journal.RollbackTo(mark)
+			lastErr = err
+			missing = append(missing, target)
+			continue
+		}
+		healed = append(healed, target)
+	}
+
+	c.MissingPortals = missing
+	if !c.IsMultipathEnabled() && len(c.Devices) > 1 {
+		// retried by the next heal, e.g. once the volume is no longer mounted
+		if err := c.addMultipathDevice(); err != nil {
+			klog.V(2).Infof("Could not add multipath device of volume %s: %v", c.VolumeName, err)
+		}
+	}
+	if lastErr != nil {
+		return healed, fmt.Errorf("could not heal paths through portals %v, last error seen: %v", missing, lastErr)
+	}
+	return healed, nil
+}
+
+// addMultipathDevice asks multipathd to create the map of the devices of a connector attached through
+// a single path, and makes it the mount target device once the devices are coalesced into it. The map
+// cannot be created while the single path is held open exclusively, e.g. by a mounted filesystem.
+func (c *Connector) addMultipathDevice() error {
+	wwid, err := c.MountTargetDevice.WWID()
+	if err != nil {
+		return fmt.Errorf("could not find WWID of device %s: %v", c.MountTargetDevice.Name, err)
+	}
+	if err := MultipathdAddMap(wwid); err != nil {
+		return err
+	}
+	if err := c.refreshDevices(); err != nil {
+		return err
+	}
+	multipathDevice, err := getMultipathDevice(c.Devices)
+	if err != nil {
+		return fmt.Errorf("multipath device of %s not assembled: %v", wwid, err)
+	}
+	klog.V(2).Infof("Added multipath device %s of volume %s", multipathDevice.Name, c.VolumeName)
+	c.MountTargetDevice = multipathDevice
+	return nil
+}
+
+// addPath adds the device of a new path to the connector and to its multipath device
+func (c *Connector) addPath(devicePath string) error {
+	devices, err := GetISCSIDevices([]string{devicePath}, true)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return fmt.Errorf("device %s not found", devicePath)
+	}
+
+	for i := range devices {
+		device := &devices[i]
+		known := false
+		for _, d := range c.Devices {
+			known = known || d.Name == device.Name
+		}
+		if known {
+			continue
+		}
+
+		if err := device.Rescan(); err != nil {
+			klog.V(2).Infof("Could not rescan device %s: %v", device.Name, err)
+		}
+		if len(device.Children) == 0 {
+			if err := MultipathdAddPath(device); err != nil {
+				return err
+			}
+		}
+		c.Devices = append(c.Devices, *device)
+	}
+
+	return nil
+}
+
+// portalWithPort returns the portal with the default port if it does not specify one
+func portalWithPort(target string) string {
+	if !strings.Contains(target, ":") {
+		return strings.Join([]string{target, defaultPort}, ":")
+	}
+	return target
+}
+
 func (c *Connector) connectTarget(targetIqn string, target string, iFace string, iscsiTransport string) (string, error) {
 	klog.V(2).Infof("Process targetIqn: %s, portal: %s\n", targetIqn, target)
 	targetParts := strings.Split(target, ":")
@@ -321,6 +612,17 @@ func (c *Connector) connectTarget(targetIqn string, target string, iFace string,
 		targetPort = targetParts[1]
 	}
 	baseArgs := []string{"-m", "node", "-T", targetIqn, "-p", targetPortal}
+
+	// create our devicePath that we'll be looking for based on the transport being used
+	// portal with port
+	portal := strings.Join([]string{targetPortal, targetPort}, ":")
+	devicePath := strings.Join([]string{"/dev/disk/by-path/ip", portal, "iscsi", targetIqn, "lun", fmt.Sprint(c.Lun)}, "-")
+	if iscsiTransport != "tcp" {
+		devicePath = strings.Join([]string{"/dev/disk/by-path/pci", "*", "ip", portal, "iscsi", targetIqn, "lun", fmt.Sprint(c.Lun)}, "-")
+	}
+	existingDevicePath := devicePath
+	deviceExisted := pathExists(&existingDevicePath, iscsiTransport) == nil
+
 	// Rescan sessions to discover newly mapped LUNs. Do not specify the interface when rescanning
 	// to avoid establishing additional sessions to the same target.
 	if _, err := iscsiCmd(append(baseArgs, []string{"-R"}...)...); err != nil {
@@ -335,20 +637,15 @@ func (c *Connector) connectTarget(targetIqn string, target string, iFace string,
 		}
 	}
 
-	// create our devicePath that we'll be looking for based on the transport being used
-	// portal with port
-	portal := strings.Join([]string{targetPortal, targetPort}, ":")
-	devicePath := strings.Join([]string{"/dev/disk/by-path/ip", portal, "iscsi", targetIqn, "lun", fmt.Sprint(c.Lun)}, "-")
-	if iscsiTransport != "tcp" {
-		devicePath = strings.Join([]string{"/dev/disk/by-path/pci", "*", "ip", portal, "iscsi", targetIqn, "lun", fmt.Sprint(c.Lun)}, "-")
-	}
-
 	exists, _ := sessionExists(portal, targetIqn)
 	if exists {
 		klog.V(2).Infof("Session already exists, checking if device path %q exists", devicePath)
 		if err := waitForPathToExist(&devicePath, c.RetryCount, c.CheckInterval, iscsiTransport); err != nil {
 			return "", err
 		}
+		if !deviceExisted {
+			c.recordDevice(devicePath)
+		}
 		return devicePath, nil
 	}
 
@@ -362,22 +659,58 @@ func (c *Connector) connectTarget(targetIqn string, target string, iFace string,
 		klog.V(2).Infof("Failed to login: %v", err)
 		return "", err
 	}
+	c.record("login "+portal, func() error {
+		return Logout(targetIqn, portal)
+	})
 
 	klog.V(2).Infof("Waiting for device path %q to exist", devicePath)
 	if err := waitForPathToExist(&devicePath, c.RetryCount, c.CheckInterval, iscsiTransport); err != nil {
 		return "", err
 	}
+	c.recordDevice(devicePath)
 
 	return devicePath, nil
 }
 
+// record adds a completed step to the journal of the ongoing connection, if any
+func (c *Connector) record(name string, undo func() error) {
+	if c.journal != nil {
+		c.journal.Record(name, undo)
+	}
+}
+
+// recordDevice records a device that appeared during the ongoing connection, so that it is removed
+// if the connection is rolled back
+func (c *Connector) recordDevice(devicePath string) {
+	c.createdDevices++
+	devices, err := GetISCSIDevices([]string{devicePath}, true)
+	if err != nil {
+		klog.V(2).Infof("Could not get info about device %s: %v", devicePath, err)
+		return
+	}
+	for i := range devices {
+		devices[i].Children = nil
+	}
+	c.record("device "+devicePath, func() error {
+		return RemoveSCSIDevices(devices...)
+	})
+}
+
 func (c *Connector) discoverTarget(targetIqn string, iFace string, portal string) error {
+	nodeRecordExisted := NodeRecordExists(targetIqn, portal)
+
 	if c.DoDiscovery {
+		discoveryRecordExisted := DiscoveryRecordExists(portal, iFace)
 		// build discoverydb and discover iscsi target
 		if err := Discoverydb(portal, iFace, c.DiscoverySecrets, c.DoCHAPDiscovery); err != nil {
 			klog.V(2).Infof("Error in discovery of the target: %s\n", err.Error())
 			return err
 		}
+		if !discoveryRecordExisted {
+			c.record("discovery record "+portal, func() error {
+				return DeleteDiscoveryRecord(portal, iFace)
+			})
+		}
 	}
 
 	if c.DoCHAPDiscovery {
@@ -389,16 +722,41 @@ func (c *Connector) discoverTarget(targetIqn string, iFace string, portal string
 		}
 	}
 
+	if !nodeRecordExisted {
+		c.record("node record "+portal, func() error {
+			// a failed login already deletes the node record
+			if !NodeRecordExists(targetIqn, portal) {
+				return nil
+			}
+			return DeleteNodeRecord(targetIqn, portal)
+		})
+	}
+
 	return nil
 }
 
+// requiredPaths returns the minimal number of paths that must be up for the connection to succeed
+func (c *Connector) requiredPaths() int {
+	if c.MinPaths == MinPathsAll {
+		return len(c.TargetPortals)
+	}
+	if c.MinPaths < 1 {
+		return 1
+	}
+	return c.MinPaths
+}
+
 // Disconnect is for backward-compatibility with c.Disconnect()
 func Disconnect(targetIqn string, targets []string) {
 	for _, target := range targets {
 		targetPortal := strings.Split(target, ":")[0]
+		if exists, err := sessionExists(portalWithPort(target), targetIqn); err == nil && !exists {
+			klog.V(2).Infof("No session to portal %s, skipping logout", target)
+			continue
+		}
 		err := Logout(targetIqn, targetPortal)
 		if err != nil {
-			return
+			klog.V(2).Infof("Could not logout from portal %s: %v", target, err)
 		}
 	}
 
@@ -416,7 +774,15 @@ func Disconnect(targetIqn string, targets []string) {
 // Disconnect performs a disconnect operation from an appliance.
 // Be sure to disconnect all devices properly before doing this as it can result in data loss.
 func (c *Connector) Disconnect() {
-	Disconnect(c.TargetIqn, c.TargetPortals)
+	Disconnect(c.TargetIqn, c.LoggedInPortals())
+}
+
+// LoggedInPortals returns the portals the connector logs in to
+func (c *Connector) LoggedInPortals() []string {
+	if c.PortalMode == PortalModeFailover && c.ActivePortal != "" {
+		return []string{c.ActivePortal}
+	}
+	return c.TargetPortals
 }
 
 // DisconnectVolume removes a volume from a Linux host.
@@ -431,6 +797,11 @@ func (c *Connector) DisconnectVolume() error {
 	// DisconnectVolume focuses on step 2 and 3.
 	// Note: make sure the volume is already unmounted before calling this method.
 
+	if c.MountTargetDevice == nil {
+		klog.V(2).Infof("Mount target device is gone, removing remaining devices %v.\n", c.Devices)
+		return RemoveSCSIDevices(c.Devices...)
+	}
+
 	if c.IsMultipathEnabled() {
 		if err := c.IsMultipathConsistent(); err != nil {
 			return fmt.Errorf("multipath is inconsistent: %v", err)
@@ -447,7 +818,14 @@ func (c *Connector) DisconnectVolume() error {
 	} else {
 		devicePath := c.MountTargetDevice.GetPath()
 		klog.V(2).Infof("Removing normal device in path %s.\n", devicePath)
-		if err := RemoveSCSIDevices(*c.MountTargetDevice); err != nil {
+		// the paths healed without multipath device are removed along
+		devices := []Device{*c.MountTargetDevice}
+		for _, device := range c.Devices {
+			if device.Name != c.MountTargetDevice.Name {
+				devices = append(devices, device)
+			}
+		}
+		if err := RemoveSCSIDevices(devices...); err != nil {
 			return err
 		}
 	}
@@ -456,10 +834,64 @@ func (c *Connector) DisconnectVolume() error {
 	return nil
 }
 
+// FailQueuedIO makes the I/O queued on the multipath device of the connector fail instead of blocking
+// while its paths are down. It is the first step of a forced disconnection, so that unmounting does
+// not hang on an unreachable target.
+func (c *Connector) FailQueuedIO(timeout time.Duration) error {
+	if c.MountTargetDevice == nil || !c.IsMultipathEnabled() {
+		return nil
+	}
+
+	return RunWithTimeout("fail_if_no_path", timeout, func() error {
+		return FailMultipathQueuedIO(c.MountTargetDevice)
+	})
+}
+
+// ForceDisconnectVolume removes a volume from a Linux host when its target is unreachable. Unlike
+// DisconnectVolume, it does not flush the buffered writes of the devices and every step runs under
+// the timeout. Writes that did not reach the target are lost.
+// Note: make sure the volume is already unmounted before calling this method.
+func (c *Connector) ForceDisconnectVolume(timeout time.Duration) error {
+	klog.Warningf("Forcing disconnection of volume %s: writes that did not reach the target are discarded, data loss is possible", c.VolumeName)
+
+	multipathDevice := c.MountTargetDevice
+	if multipathDevice != nil && !c.IsMultipathEnabled() {
+		multipathDevice = nil
+	}
+	if multipathDevice != nil {
+		err := RunWithTimeout("multipath flush", timeout, func() error {
+			return FlushMultipathDevice(multipathDevice)
+		})
+		if err != nil {
+			klog.V(2).Infof("Could not flush multipath device %s, it will be removed after its paths: %v", multipathDevice.Name, err)
+		}
+	}
+
+	devices := c.Devices
+	if len(devices) == 0 && c.MountTargetDevice != nil && multipathDevice == nil {
+		devices = []Device{*c.MountTargetDevice}
+	}
+	if err := ForceRemoveSCSIDevices(timeout, devices...); err != nil {
+		return err
+	}
+
+	if multipathDevice != nil && multipathDevice.Exists() == nil {
+		err := RunWithTimeout("multipath removal", timeout, func() error {
+			return RemoveMultipathDevice(multipathDevice)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	klog.V(2).Infof("Finished forcing disconnection of volume.\n")
+	return nil
+}
+
 // getMountTargetDevice returns the device to be mounted among the configured devices
 func (c *Connector) getMountTargetDevice() (*Device, error) {
 	if len(c.Devices) > 1 {
-		multipathDevice, err := getMultipathDevice(c.Devices)
+		multipathDevice, err := c.waitForMultipathDevice()
 		if err != nil {
 			klog.V(2).Infof("mount target is not a multipath device: %v", err)
 			return nil, err
@@ -472,7 +904,108 @@ func (c *Connector) getMountTargetDevice() (*Device, error) {
 		return nil, fmt.Errorf("could not find mount target device: connector does not contain any device")
 	}
 
-	return &c.Devices[0], nil
+	// In degraded mode a single path of a multipath LUN may be up, mount its multipath device
+	// so that the missing paths can be added to it later on.
+	device := &c.Devices[0]
+	if len(c.TargetPortals) > 1 && len(device.Children) == 1 && device.Children[0].Type == "mpath" {
+		klog.V(2).Infof("mount target is the multipath device of the only path up")
+		return &device.Children[0], nil
+	}
+
+	return device, nil
+}
+
+// waitForMultipathDevice waits for multipathd to coalesce the devices of the connector into
+// a single multipath device, refreshing the devices between attempts
+func (c *Connector) waitForMultipathDevice() (*Device, error) {
+	var lastErr error
+	for i := uint(0); i <= c.RetryCount; i++ {
+		if i != 0 {
+			klog.V(2).Infof("Multipath device is not assembled yet, retrying in %d seconds (%d/%d): %v", c.CheckInterval, i, c.RetryCount, lastErr)
+			sleep(time.Second * time.Duration(c.CheckInterval))
+			if err := c.refreshDevices(); err != nil {
+				return nil, err
+			}
+		}
+
+		multipathDevice, err := getMultipathDevice(c.Devices)
+		if err == nil {
+			return multipathDevice, nil
+		}
+		lastErr = err
+
+		if i == 0 && c.MultipathdAdd {
+			c.addDevicesToMultipathd()
+		}
+	}
+
+	return nil, c.diagnoseMultipathDevice(lastErr)
+}
+
+// refreshDevices reloads the devices of the connector to get their current children
+func (c *Connector) refreshDevices() error {
+	devicePaths := []string{}
+	for _, device := range c.Devices {
+		devicePaths = append(devicePaths, device.GetPath())
+	}
+
+	devices, err := GetISCSIDevices(devicePaths, true)
+	if err != nil {
+		return err
+	}
+	c.Devices = devices
+	return nil
+}
+
+// addDevicesToMultipathd asks multipathd to add the paths of the connector that are not part of a
+// multipath device yet, and the map for their WWID
+func (c *Connector) addDevicesToMultipathd() {
+	wwids := map[string]bool{}
+	for i := range c.Devices {
+		device := &c.Devices[i]
+		if len(device.Children) != 0 {
+			continue
+		}
+		if err := MultipathdAddPath(device); err != nil {
+			klog.V(2).Infof("Could not add path %s to multipathd: %v", device.Name, err)
+		}
+		wwid, err := device.WWID()
+		if err != nil {
+			klog.V(2).Infof("Could not find WWID for device %s: %v", device.Name, err)
+			continue
+		}
+		wwids[wwid] = true
+	}
+
+	for wwid := range wwids {
+		if err := MultipathdAddMap(wwid); err != nil {
+			klog.V(2).Infof("Could not add map %s to multipathd: %v", wwid, err)
+		}
+	}
+}
+
+// diagnoseMultipathDevice explains why the devices of the connector were not coalesced into a
+// single multipath device
+func (c *Connector) diagnoseMultipathDevice(lastErr error) error {
+	if !IsMultipathdRunning() {
+		return fmt.Errorf("multipath device not assembled: multipathd is not running")
+	}
+
+	names := []string{}
+	for i := range c.Devices {
+		device := &c.Devices[i]
+		names = append(names, device.Name)
+		if !IsMultipathBlacklisted(device) {
+			continue
+		}
+		wwid, err := device.WWID()
+		if err != nil {
+			wwid = "unknown"
+		}
+		return fmt.Errorf("multipath device not assembled: WWID %s of device %s is blacklisted in the multipath configuration", wwid, device.Name)
+	}
+
+	return fmt.Errorf("multipath device not assembled: paths %v did not coalesce into a single multipath device after %d retries: %v", names, c.RetryCount, lastErr)
 }
 
 // IsMultipathEnabled check if multipath is enabled on devices handled by this connector
@@ -480,6 +1013,20 @@ func (c *Connector) IsMultipathEnabled() bool {
 	return c.MountTargetDevice.Type == "mpath"
 }
 
+// Resize rescans the devices handled by this connector, so that they pick up the new size of their
+// LUN, and resizes their multipath device
+func (c *Connector) Resize() error {
+	for _, device := range c.Devices {
+		if err := device.Rescan(); err != nil {
+			return fmt.Errorf("failed to rescan device %s: %v", device.Name, err)
+		}
+	}
+	if c.IsMultipathEnabled() {
+		return ResizeMultipathDevice(c.MountTargetDevice)
+	}
+	return nil
+}
+
 // GetSCSIDevices get SCSI devices from device paths
 // It will returns all SCSI devices if no paths are given
 func GetSCSIDevices(devicePaths []string, strict bool) ([]Device, error) {
@@ -643,6 +1190,34 @@ func RemoveSCSIDevices(devices ...Device) error {
 	return nil
 }
 
+// ForceRemoveSCSIDevices removes SCSI device(s) from a Linux host without flushing their buffered
+// writes, each step giving up after the timeout. It is meant for devices of an unreachable target.
+func ForceRemoveSCSIDevices(timeout time.Duration, devices ...Device) error {
+	klog.V(2).Infof("Forcing removal of SCSI devices %v.\n", devices)
+
+	var errs []error
+	for _, device := range devices {
+		klog.V(2).Infof("Put SCSI device %q offline.\n", device.Name)
+		err := RunWithTimeout("offline of "+device.Name, timeout, device.Shutdown)
+		if err != nil && !os.IsNotExist(err) {
+			// deleting the device is still attempted, an offline device is not required for it
+			klog.V(2).Infof("Could not put SCSI device %q offline: %v", device.Name, err)
+		}
+
+		klog.V(2).Infof("Delete SCSI device %q.\n", device.Name)
+		err = RunWithTimeout("deletion of "+device.Name, timeout, device.Delete)
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	klog.V(2).Infof("Finished forcing removal of SCSI devices.")
+	return nil
+}
+
 // PersistConnector is for backward-compatibility with c.Persist()
 func PersistConnector(c *Connector, filePath string) error {
 	return c.Persist(filePath)
@@ -663,8 +1238,9 @@ func (c *Connector) Persist(filePath string) error {
 	return nil
 }
 
-// GetConnectorFromFile attempts to create a Connector using the specified json file (ie /var/lib/pfile/myConnector.json)
-func GetConnectorFromFile(filePath string) (*Connector, error) {
+// LoadConnectorFromFile reads the Connector persisted in the specified json file as is,
+// without looking up its devices on the host
+func LoadConnectorFromFile(filePath string) (*Connector, error) {
 	f, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -675,6 +1251,16 @@ func GetConnectorFromFile(filePath string) (*Connector, error) {
 		return nil, err
 	}
 
+	return &c, nil
+}
+
+// GetConnectorFromFile attempts to create a Connector using the specified json file (ie /var/lib/pfile/myConnector.json)
+func GetConnectorFromFile(filePath string) (*Connector, error) {
+	c, err := LoadConnectorFromFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
 	devicePaths := []string{}
 	for _, device := range c.Devices {
 		devicePaths = append(devicePaths, device.GetPath())
@@ -695,7 +1281,86 @@ func GetConnectorFromFile(filePath string) (*Connector, error) {
 		return nil, err
 	}
 
-	return &c, nil
+	return c, nil
+}
+
+// LoadRemainingConnectorFromFile reads the Connector persisted in the specified json file and looks up
+// its devices that still exist on the host. Unlike GetConnectorFromFile it does not fail when devices
+// are gone, e.g. after a node reboot or when the target was removed, so that whatever is left of the
+// connection can be cleaned up. Devices are only kept if they still belong to the target of the
+// connector, as device names are reused after a reboot.
+func LoadRemainingConnectorFromFile(filePath string) (*Connector, error) {
+	c, err := LoadConnectorFromFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := c.ownedDevicePaths()
+	devicePaths := []string{}
+	for _, device := range c.Devices {
+		if !owned[device.GetPath()] {
+			klog.V(2).Infof("Device %s no longer belongs to target %s, skipping it", device.GetPath(), c.TargetIqn)
+			continue
+		}
+		devicePaths = append(devicePaths, device.GetPath())
+	}
+
+	mountTargetDevice := c.MountTargetDevice
+	c.MountTargetDevice = nil
+	c.Devices = []Device{}
+	if len(devicePaths) == 0 {
+		return c, nil
+	}
+	if c.Devices, err = GetSCSIDevices(devicePaths, false); err != nil {
+		return nil, err
+	}
+
+	if mountTargetDevice == nil {
+		return c, nil
+	}
+	if owned[mountTargetDevice.GetPath()] {
+		for i := range c.Devices {
+			if c.Devices[i].Name == mountTargetDevice.Name {
+				c.MountTargetDevice = &c.Devices[i]
+			}
+		}
+		return c, nil
+	}
+	// a multipath device is kept only if it is still the parent of one of the remaining devices
+	for _, device := range c.Devices {
+		for i := range device.Children {
+			if device.Children[i].Name == mountTargetDevice.Name && device.Children[i].Type == "mpath" {
+				c.MountTargetDevice = &device.Children[i]
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// ownedDevicePaths returns the paths of the devices that currently exist for the target and LUN of the connector
+func (c *Connector) ownedDevicePaths() map[string]bool {
+	owned := map[string]bool{}
+	for _, target := range c.LoggedInPortals() {
+		portal := portalWithPort(target)
+		patterns := []string{
+			strings.Join([]string{"/dev/disk/by-path/ip", portal, "iscsi", c.TargetIqn, "lun", fmt.Sprint(c.Lun)}, "-"),
+			strings.Join([]string{"/dev/disk/by-path/pci", "*", "ip", portal, "iscsi", c.TargetIqn, "lun", fmt.Sprint(c.Lun)}, "-"),
+		}
+		for _, pattern := range patterns {
+			paths, err := filepathGlob(pattern)
+			if err != nil {
+				continue
+			}
+			for _, path := range paths {
+				if devicePath, err := filepath.EvalSymlinks(path); err == nil {
+					owned[devicePath] = true
+				}
+			}
+		}
+	}
+
+	return owned
 }
 
 // IsMultipathConsistent check if the currently used device is using a consistent multipath mapping
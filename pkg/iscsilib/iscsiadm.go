@@ -190,6 +190,34 @@ func Logout(tgtIQN, portal string) error {
 	return err
 }
 
+// NodeRecordExists checks if the iscsi db has a node entry for the specified target and portal
+func NodeRecordExists(tgtIQN, portal string) bool {
+	klog.V(2).Infof("Begin NodeRecordExists...")
+	_, err := iscsiCmd("-m", "node", "-T", tgtIQN, "-p", portal)
+	return err == nil
+}
+
+// DeleteNodeRecord deletes the iscsi db node entry for the specified target and portal
+func DeleteNodeRecord(tgtIQN, portal string) error {
+	klog.V(2).Infof("Begin DeleteNodeRecord...")
+	_, err := iscsiCmd("-m", "node", "-T", tgtIQN, "-p", portal, "-o", "delete")
+	return err
+}
+
+// DiscoveryRecordExists checks if the iscsi discoverydb has a sendtargets entry for the specified portal
+func DiscoveryRecordExists(portal, iface string) bool {
+	klog.V(2).Infof("Begin DiscoveryRecordExists...")
+	_, err := iscsiCmd("-m", "discoverydb", "-t", "sendtargets", "-p", portal, "-I", iface)
+	return err == nil
+}
+
+// DeleteDiscoveryRecord deletes the iscsi discoverydb sendtargets entry for the specified portal
+func DeleteDiscoveryRecord(portal, iface string) error {
+	klog.V(2).Infof("Begin DeleteDiscoveryRecord...")
+	_, err := iscsiCmd("-m", "discoverydb", "-t", "sendtargets", "-p", portal, "-I", iface, "-o", "delete")
+	return err
+}
+
 // DeleteDBEntry deletes the iscsi db entry for the specified target
 func DeleteDBEntry(tgtIQN string) error {
 	klog.V(2).Infof("Begin DeleteDBEntry...")
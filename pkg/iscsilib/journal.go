@@ -0,0 +1,84 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iscsilib
+
+import (
+	"fmt"
+
+	klog "k8s.io/klog/v2"
+)
+
+// Journal records the steps completed by a multi-step operation, so that they can be undone in
+// reverse order when a later step fails
+type Journal struct {
+	steps []journalStep
+}
+
+type journalStep struct {
+	name string
+	undo func() error
+}
+
+// Record adds a completed step to the journal, undo is nil for steps that have nothing to undo
+func (j *Journal) Record(name string, undo func() error) {
+	klog.V(4).Infof("Journal step completed: %s", name)
+	j.steps = append(j.steps, journalStep{name: name, undo: undo})
+}
+
+// Steps returns the names of the completed steps, in the order they were completed
+func (j *Journal) Steps() []string {
+	names := []string{}
+	for _, step := range j.steps {
+		names = append(names, step.name)
+	}
+	return names
+}
+
+// Mark returns a position in the journal that can be rolled back to
+func (j *Journal) Mark() int {
+	return len(j.steps)
+}
+
+// Rollback undoes all the completed steps in reverse order
+func (j *Journal) Rollback() error {
+	return j.RollbackTo(0)
+}
+
+// RollbackTo undoes in reverse order the steps completed after the given mark. It goes through all
+// the steps even if some of them fail to be undone, and returns the first error.
+func (j *Journal) RollbackTo(mark int) error {
+	var firstErr error
+	for i := len(j.steps) - 1; i >= mark; i-- {
+		step := j.steps[i]
+		if step.undo == nil {
+			klog.V(2).Infof("Nothing to undo for step %q", step.name)
+			continue
+		}
+		klog.V(2).Infof("Undoing step %q", step.name)
+		if err := step.undo(); err != nil {
+			klog.Warningf("Failed to undo step %q: %v", step.name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to undo step %q: %v", step.name, err)
+			}
+		}
+	}
+	if mark < len(j.steps) {
+		j.steps = j.steps[:mark]
+	}
+
+	return firstErr
+}
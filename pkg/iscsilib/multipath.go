@@ -62,6 +62,21 @@ func ExecWithTimeout(command string, args []string, timeout time.Duration) ([]by
 	return out, err
 }
 
+// RunWithTimeout runs a function and stops waiting for it when the timeout is exceeded. The function
+// keeps running in the background, as operations blocked on I/O in the kernel cannot be interrupted.
+func RunWithTimeout(name string, timeout time.Duration, f func() error) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- f() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		klog.V(2).Infof("%s did not complete within %v, giving up waiting for it.\n", name, timeout)
+		return fmt.Errorf("%s did not complete within %v: %w", name, timeout, os.ErrDeadlineExceeded)
+	}
+}
+
 // FlushMultipathDevice flushes a multipath device dm-x with command multipath -f /dev/dm-x
 func FlushMultipathDevice(device *Device) error {
 	devicePath := device.GetPath()
@@ -95,3 +110,71 @@ func ResizeMultipathDevice(device *Device) error {
 
 	return nil
 }
+
+// IsMultipathdRunning checks if the multipathd daemon answers to commands
+func IsMultipathdRunning() bool {
+	timeout := 2 * time.Second
+	if _, err := execWithTimeout("multipathd", []string{"show", "daemon"}, timeout); err != nil {
+		klog.V(2).Infof("multipathd does not seem to be running: %v", err)
+		return false
+	}
+
+	return true
+}
+
+// IsMultipathBlacklisted checks if a device is excluded from multipath by the blacklist
+func IsMultipathBlacklisted(device *Device) bool {
+	// multipath -c exits with a non-zero code for blacklisted devices, the verbose output
+	// tells them apart from devices that are not valid paths for another reason
+	out, _ := execCommand("multipath", "-v3", "-c", device.GetPath()).CombinedOutput()
+	return strings.Contains(string(out), "blacklisted")
+}
+
+// MultipathdAddPath asks multipathd to add a device as a path with command multipathd add path
+func MultipathdAddPath(device *Device) error {
+	klog.V(2).Infof("Adding path %s to multipathd\n", device.Name)
+
+	timeout := 5 * time.Second
+	if _, err := execWithTimeout("multipathd", []string{"add", "path", device.Name}, timeout); err != nil {
+		return fmt.Errorf("could not add path %s to multipathd: %v", device.Name, err)
+	}
+
+	return nil
+}
+
+// MultipathdAddMap asks multipathd to create the multipath device of a WWID with command multipathd add map
+func MultipathdAddMap(wwid string) error {
+	klog.V(2).Infof("Adding map %s to multipathd\n", wwid)
+
+	timeout := 5 * time.Second
+	if _, err := execWithTimeout("multipathd", []string{"add", "map", wwid}, timeout); err != nil {
+		return fmt.Errorf("could not add map %s to multipathd: %v", wwid, err)
+	}
+
+	return nil
+}
+
+// FailMultipathQueuedIO switches a multipath device to fail_if_no_path, so that the I/O queued while
+// all its paths are down fails instead of blocking
+func FailMultipathQueuedIO(device *Device) error {
+	klog.V(2).Infof("Switching multipath device %s to fail_if_no_path\n", device.Name)
+
+	timeout := 5 * time.Second
+	if _, err := execWithTimeout("dmsetup", []string{"message", device.Name, "0", "fail_if_no_path"}, timeout); err != nil {
+		return fmt.Errorf("could not switch multipath device %s to fail_if_no_path: %v", device.Name, err)
+	}
+
+	return nil
+}
+
+// RemoveMultipathDevice forcibly removes a multipath device with command dmsetup remove --force
+func RemoveMultipathDevice(device *Device) error {
+	klog.V(2).Infof("Removing multipath device %s\n", device.Name)
+
+	timeout := 5 * time.Second
+	if _, err := execWithTimeout("dmsetup", []string{"remove", "--force", device.Name}, timeout); err != nil {
+		return fmt.Errorf("could not remove multipath device %s: %v", device.Name, err)
+	}
+
+	return nil
+}
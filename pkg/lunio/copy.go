@@ -0,0 +1,86 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lunio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	klog "k8s.io/klog/v2"
+)
+
+// checkpointInterval is the number of bytes copied between two checkpoints of a copy
+const checkpointInterval = 256 << 20
+
+// Progress is the progress of a copy between two LUNs
+type Progress struct {
+	// Offset is the number of bytes copied, an interrupted copy resumes from there
+	Offset int64 `json:"offset"`
+	// Size is the number of bytes to copy
+	Size int64 `json:"size"`
+}
+
+// CopyLUN attaches two LUNs to the host, copies the first size bytes of src to dst from offset, and
+// detaches them. Unlike CopySparse, the chunks of src that only hold zeros are written to dst if dst
+// does not read as zeros there, so dst can be any LUN. The bytes up to the offset of the progress
+// passed to checkpoint are synced to dst, so that a copy interrupted by the cancellation of ctx, a
+// failure or a restart can resume from there.
+func CopyLUN(ctx context.Context, name string, src, dst *backend.Access, size, offset int64, checkpoint func(Progress) error) (err error) {
+	srcAttachment, err := Attach(name+"-source", src.Target, src.CHAP)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, srcAttachment.Detach()) }()
+	dstAttachment, err := Attach(name, dst.Target, dst.CHAP)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, dstAttachment.Detach()) }()
+
+	in, err := os.Open(srcAttachment.Device)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dstAttachment.Device, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	klog.V(2).Infof("lunio: copying %s from offset %d of %d bytes", name, offset, size)
+	lastCheckpoint := offset
+	err = copyRange(ctx, out, out, in, offset, size, func(offset int64) error {
+		if offset-lastCheckpoint < checkpointInterval && offset < size {
+			return nil
+		}
+		if err := out.Sync(); err != nil {
+			return err
+		}
+		lastCheckpoint = offset
+		return checkpoint(Progress{Offset: offset, Size: size})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %v", name, err)
+	}
+
+	klog.V(2).Infof("lunio: copied %d bytes of %s", size, name)
+	return nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lunio attaches LUNs to the host the controller service runs on, through iscsilib, to read
+// or write their content, e.g. to wipe a LUN that is returned to its pool.
+package lunio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+	klog "k8s.io/klog/v2"
+)
+
+// chunkSize is the size of the writes to a LUN
+const chunkSize = 1 << 20
+
+var (
+	connect          = (*iscsiLib.Connector).ConnectWithJournal
+	disconnectVolume = (*iscsiLib.Connector).DisconnectVolume
+	logout           = iscsiLib.Logout
+	filepathGlob     = filepath.Glob
+)
+
+// sessions holds the targets with attached LUNs, by IQN. The sessions to a target are shared by
+// the LUNs attached through it, lunio only logs out of those it logged in to, once no LUN of the
+// target is attached anymore.
+var (
+	sessionsLock sync.Mutex
+	sessions     = map[string]*session{}
+)
+
+type session struct {
+	// attachments is the number of LUNs of the target attached or being attached by lunio
+	attachments int
+	// portals are the portals lunio logged in to
+	portals map[string]bool
+}
+
+// Attachment is a LUN attached to the host
+type Attachment struct {
+	// Device is the path of the block device of the LUN
+	Device string
+
+	connector *iscsiLib.Connector
+}
+
+// Attach logs in to the target of a LUN, unless a session to it already exists, and returns its
+// block device. The name identifies the attachment in the logs.
+func Attach(name string, target backend.Target, chap *backend.CHAP) (*Attachment, error) {
+	connector := &iscsiLib.Connector{
+		VolumeName:    name,
+		TargetIqn:     target.IQN,
+		TargetPortals: target.Portals,
+		Lun:           target.LUN,
+		DoDiscovery:   true,
+	}
+	if chap != nil {
+		connector.AuthType = "chap"
+		connector.SessionSecrets = iscsiLib.Secrets{SecretsType: "chap", UserName: chap.User, Password: chap.Password}
+	}
+
+	// the attachment is counted before connecting, so that the detachment of another LUN of the
+	// target does not log out of the sessions this one is about to use
+	sessionsLock.Lock()
+	s := sessions[target.IQN]
+	if s == nil {
+		s = &session{portals: map[string]bool{}}
+		sessions[target.IQN] = s
+	}
+	s.attachments++
+	sessionsLock.Unlock()
+
+	journal := &iscsiLib.Journal{}
+	device, err := connect(connector, journal)
+
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	if err != nil {
+		if rollbackErr := journal.Rollback(); rollbackErr != nil {
+			klog.Warningf("lunio: failed to roll back the attachment of %s: %v", name, rollbackErr)
+		}
+		s.release(target.IQN)
+		return nil, fmt.Errorf("failed to attach %s: %v", name, err)
+	}
+	for _, step := range journal.Steps() {
+		if portal, ok := strings.CutPrefix(step, "login "); ok {
+			s.portals[portal] = true
+		}
+	}
+	klog.V(2).Infof("lunio: attached %s as %s", name, device)
+	return &Attachment{Device: device, connector: connector}, nil
+}
+
+// Detach removes the block device of the LUN. It logs out of the sessions lunio logged in to if no
+// other LUN of the target is attached.
+func (a *Attachment) Detach() error {
+	if err := disconnectVolume(a.connector); err != nil {
+		return fmt.Errorf("failed to detach %s: %v", a.connector.VolumeName, err)
+	}
+
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	if s := sessions[a.connector.TargetIqn]; s != nil {
+		s.release(a.connector.TargetIqn)
+	}
+	klog.V(2).Infof("lunio: detached %s", a.connector.VolumeName)
+	return nil
+}
+
+// release uncounts an attachment of the target, and logs out of the portals lunio logged in to when
+// it was the last one. The portals through which LUNs not attached by lunio remain are kept, e.g.
+// the LUNs of the volumes published on the host. sessionsLock must be held.
+func (s *session) release(iqn string) {
+	s.attachments--
+	if s.attachments > 0 {
+		return
+	}
+	for portal := range s.portals {
+		if devices, err := targetDevices(iqn, portal); err != nil || len(devices) > 0 {
+			klog.V(2).Infof("lunio: keeping the session to %s at %s, attached devices: %v, err: %v", iqn, portal, devices, err)
+			continue
+		}
+		if err := logout(iqn, portal); err != nil {
+			klog.Warningf("lunio: failed to log out of %s at %s: %v", iqn, portal, err)
+			continue
+		}
+		klog.V(2).Infof("lunio: logged out of %s at %s", iqn, portal)
+	}
+	delete(sessions, iqn)
+}
+
+// targetDevices returns the devices of the LUNs of a target attached through a portal
+func targetDevices(iqn, portal string) ([]string, error) {
+	return filepathGlob(fmt.Sprintf("/dev/disk/by-path/*ip-%s-iscsi-%s-lun-*", portal, iqn))
+}
+
+// CopySparse copies the first size bytes of src to dst, skipping the chunks that only hold zeros,
+// which are expected to read as zeros from dst already, e.g. a new sparse file or thin LUN
+func CopySparse(dst io.WriterAt, src io.ReaderAt, size int64) error {
+	return copyRange(context.Background(), dst, nil, src, 0, size, nil)
+}
+
+// copyRange copies the bytes of src from offset to size to dst by chunks. The chunks of src that
+// only hold zeros are skipped if dstReader is nil, or if they read as zeros from dstReader. progress
+// is called with the offset reached after each chunk, if not nil.
+func copyRange(ctx context.Context, dst io.WriterAt, dstReader io.ReaderAt, src io.ReaderAt, offset, size int64, progress func(offset int64) error) error {
+	buf := make([]byte, chunkSize)
+	dstBuf := make([]byte, chunkSize)
+	zeros := make([]byte, chunkSize)
+	for ; offset < size; offset += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := int(min(chunkSize, size-offset))
+		if err := readChunk(src, buf[:n], offset); err != nil {
+			return fmt.Errorf("failed to read at offset %d: %v", offset, err)
+		}
+		write := !bytes.Equal(buf[:n], zeros[:n])
+		if !write && dstReader != nil {
+			if err := readChunk(dstReader, dstBuf[:n], offset); err != nil {
+				return fmt.Errorf("failed to read destination at offset %d: %v", offset, err)
+			}
+			write = !bytes.Equal(dstBuf[:n], zeros[:n])
+		}
+		if write {
+			if _, err := dst.WriteAt(buf[:n], offset); err != nil {
+				return fmt.Errorf("failed to write at offset %d: %v", offset, err)
+			}
+		}
+		if progress != nil {
+			if err := progress(offset + int64(n)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readChunk fills buf from offset, past the end of r reads as zeros
+func readChunk(r io.ReaderAt, buf []byte, offset int64) error {
+	read, err := r.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	clear(buf[read:])
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lunio
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	iscsiLib "github.com/kubernetes-csi/csi-driver-iscsi/pkg/iscsilib"
+)
+
+// fakeHost stubs the iSCSI initiator of the host
+type fakeHost struct {
+	// sessions are the portals logged in to
+	sessions map[string]bool
+	// devices are the devices of the LUNs attached outside lunio, by portal
+	devices map[string][]string
+	logouts []string
+}
+
+func newFakeHost(t *testing.T) *fakeHost {
+	h := &fakeHost{sessions: map[string]bool{}, devices: map[string][]string{}}
+	connect = func(c *iscsiLib.Connector, journal *iscsiLib.Journal) (string, error) {
+		for _, portal := range c.TargetPortals {
+			if !h.sessions[portal] {
+				h.sessions[portal] = true
+				journal.Record("login "+portal, func() error {
+					delete(h.sessions, portal)
+					return nil
+				})
+			}
+		}
+		if c.VolumeName == "broken" {
+			return "", errors.New("no device")
+		}
+		return "/dev/sdx", nil
+	}
+	disconnectVolume = func(c *iscsiLib.Connector) error { return nil }
+	logout = func(iqn, portal string) error {
+		delete(h.sessions, portal)
+		h.logouts = append(h.logouts, portal)
+		return nil
+	}
+	filepathGlob = func(pattern string) ([]string, error) {
+		for portal, devices := range h.devices {
+			if pattern == "/dev/disk/by-path/*ip-"+portal+"-iscsi-iqn.2026-01.io.example:array1-lun-*" {
+				return devices, nil
+			}
+		}
+		return nil, nil
+	}
+	t.Cleanup(func() {
+		connect = (*iscsiLib.Connector).ConnectWithJournal
+		disconnectVolume = (*iscsiLib.Connector).DisconnectVolume
+		logout = iscsiLib.Logout
+		filepathGlob = filepath.Glob
+		sessions = map[string]*session{}
+	})
+	return h
+}
+
+func TestDetachLogsOutOfOwnSessionsOnly(t *testing.T) {
+	target := func(lun int32, portals ...string) backend.Target {
+		return backend.Target{IQN: "iqn.2026-01.io.example:array1", Portals: portals, LUN: lun}
+	}
+
+	tests := []struct {
+		name string
+		// existing are the portals logged in to before the attachments
+		existing []string
+		// devices are the devices attached outside lunio, by portal
+		devices        map[string][]string
+		expectedLogout []string
+	}{
+		{
+			name:           "sessions created by lunio",
+			expectedLogout: []string{"10.0.0.1:3260"},
+		},
+		{
+			name:     "existing sessions",
+			existing: []string{"10.0.0.1:3260"},
+		},
+		{
+			name:    "LUNs attached outside lunio remain",
+			devices: map[string][]string{"10.0.0.1:3260": {"/dev/disk/by-path/ip-10.0.0.1:3260-iscsi-iqn.2026-01.io.example:array1-lun-7"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newFakeHost(t)
+			for _, portal := range test.existing {
+				h.sessions[portal] = true
+			}
+			h.devices = test.devices
+
+			first, err := Attach("first", target(1, "10.0.0.1:3260"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := Attach("second", target(2, "10.0.0.1:3260"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := first.Detach(); err != nil {
+				t.Fatal(err)
+			}
+			if len(h.logouts) > 0 {
+				t.Errorf("expected the session to be kept for the second LUN, got logouts %v", h.logouts)
+			}
+			if err := second.Detach(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(h.logouts, test.expectedLogout) {
+				t.Errorf("expected logouts %v, got %v", test.expectedLogout, h.logouts)
+			}
+			if len(sessions) > 0 {
+				t.Errorf("expected no target left, got %v", sessions)
+			}
+		})
+	}
+}
+
+func TestAttachFailureReleasesTarget(t *testing.T) {
+	h := newFakeHost(t)
+	target := backend.Target{IQN: "iqn.2026-01.io.example:array1", Portals: []string{"10.0.0.1:3260"}}
+
+	if _, err := Attach("broken", target, nil); err == nil {
+		t.Fatalf("expected the attachment to fail")
+	}
+	if len(h.sessions) > 0 {
+		t.Errorf("expected the login to be rolled back, got sessions %v", h.sessions)
+	}
+	if len(sessions) > 0 {
+		t.Errorf("expected no target left, got %v", sessions)
+	}
+}
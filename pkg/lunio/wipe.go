@@ -0,0 +1,104 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lunio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kubernetes-csi/csi-driver-iscsi/pkg/backend"
+	klog "k8s.io/klog/v2"
+)
+
+// WipeMode is how the content of a LUN is wiped
+type WipeMode string
+
+const (
+	// WipeDiscard unmaps the blocks of the LUN with BLKDISCARD, which only wipes the LUN if the
+	// storage system reads the unmapped blocks as zeros
+	WipeDiscard WipeMode = "discard"
+	// WipeZero writes zeros to the LUN with BLKZEROOUT, offloaded to the storage system when it
+	// supports WRITE SAME or WRITE ZEROES
+	WipeZero WipeMode = "zero"
+)
+
+// ParseWipePolicy parses a wipe policy, which is a wipe mode or none for an empty mode
+func ParseWipePolicy(s string) (WipeMode, error) {
+	switch mode := WipeMode(s); mode {
+	case "none":
+		return "", nil
+	case WipeDiscard, WipeZero:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid wipe policy %q: must be %s, %s or none", s, WipeDiscard, WipeZero)
+}
+
+// wipeChunkSize is the size of the ranges wiped by a single ioctl, so that a wipe can be cancelled
+const wipeChunkSize = 64 << 20
+
+// WipeLUN attaches a LUN to the host, wipes its content from the offset of progress by chunks, and
+// detaches it. A wipe of a LUN whose size differs from the size of progress starts over. The bytes up
+// to the offset of the progress passed to checkpoint are wiped, so that a wipe interrupted by the
+// cancellation of ctx, a failure or a restart can resume from there.
+func WipeLUN(ctx context.Context, name string, access *backend.Access, mode WipeMode, progress Progress, checkpoint func(Progress) error) (err error) {
+	attachment, err := Attach(name, access.Target, access.CHAP)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, attachment.Detach()) }()
+
+	f, err := os.OpenFile(attachment.Device, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	offset := progress.Offset
+	if progress.Size != size {
+		offset = 0
+	}
+
+	klog.V(2).Infof("lunio: wiping %s with %s from offset %d of %d bytes", name, mode, offset, size)
+	lastCheckpoint := offset
+	for offset < size {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := min(wipeChunkSize, size-offset)
+		if err := wipeRange(f, mode, offset, n); err != nil {
+			return fmt.Errorf("failed to wipe %s at offset %d: %v", name, offset, err)
+		}
+		offset += n
+		if offset-lastCheckpoint < checkpointInterval && offset < size {
+			continue
+		}
+		lastCheckpoint = offset
+		if err := checkpoint(Progress{Offset: offset, Size: size}); err != nil {
+			return err
+		}
+	}
+
+	klog.V(2).Infof("lunio: wiped %d bytes of %s", size, name)
+	return nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lunio
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// wipeRange discards or zeroes a range of a block device, the ioctls completing once the range is
+// wiped on the device
+func wipeRange(f *os.File, mode WipeMode, offset, length int64) error {
+	var request uintptr
+	switch mode {
+	case WipeDiscard:
+		request = unix.BLKDISCARD
+	case WipeZero:
+		request = unix.BLKZEROOUT
+	default:
+		return fmt.Errorf("unknown wipe mode %q", mode)
+	}
+	r := [2]uint64{uint64(offset), uint64(length)}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), request, uintptr(unsafe.Pointer(&r))); errno != 0 {
+		return errno
+	}
+	return nil
+}
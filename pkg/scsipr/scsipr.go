@@ -0,0 +1,155 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scsipr fences the nodes of single-writer volumes with SCSI-3 persistent reservations: the
+// node publishing a volume registers its key on every path to the LUN and holds a WRITE EXCLUSIVE -
+// REGISTRANTS ONLY reservation, so that a partitioned node that still has the LUN attached cannot write
+// to it.
+package scsipr
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	klog "k8s.io/klog/v2"
+)
+
+// Type is the type of a persistent reservation
+type Type int
+
+const (
+	// TypeWriteExclusive lets the holder of the reservation write through the I_T nexus it reserved the
+	// LUN through, and any initiator read
+	TypeWriteExclusive Type = 1
+	// TypeWriteExclusiveRegistrantsOnly lets every registered I_T nexus write, and any initiator read
+	TypeWriteExclusiveRegistrantsOnly Type = 5
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeWriteExclusive:
+		return "Write Exclusive"
+	case TypeWriteExclusiveRegistrantsOnly:
+		return "Write Exclusive, registrants only"
+	}
+	return fmt.Sprintf("type %d", int(t))
+}
+
+// Reservation is the persistent reservation held on a LUN
+type Reservation struct {
+	Key  uint64
+	Type Type
+}
+
+// ErrConflict is returned when a command is rejected with a reservation conflict
+var ErrConflict = errors.New("reservation conflict")
+
+// Interface issues the persistent reservation commands to a device, the registrations being made
+// through the I_T nexus of the device
+type Interface interface {
+	// ReadReservation returns the reservation held on the LUN, nil if there is none
+	ReadReservation(device string) (*Reservation, error)
+	// Register registers the key, replacing the key already registered through the device if any
+	Register(device string, key uint64) error
+	// Unregister removes the registration of the key through the device
+	Unregister(device string, key uint64) error
+	// Reserve takes a reservation of the LUN with a registered key
+	Reserve(device string, key uint64, t Type) error
+	// Preempt removes the registrations of another key, aborting its outstanding commands, and
+	// takes the reservation it held
+	Preempt(device string, key, preemptedKey uint64, t Type) error
+	// Release releases the reservation held with the key
+	Release(device string, key uint64, t Type) error
+}
+
+// NodeKey derives the reservation key of a node from its ID, it is never 0 as 0 unregisters
+func NodeKey(nodeID string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(nodeID))
+	if key := h.Sum64(); key != 0 {
+		return key
+	}
+	return 1
+}
+
+// Acquire registers the key through every path to a LUN and takes a WRITE EXCLUSIVE - REGISTRANTS ONLY
+// reservation with it, which lets the node write through all the paths it registered, not only the one
+// it reserved the LUN through. The reservation of another key is preempted, as the volume is published
+// on this node now. It returns the preempted key, 0 if there was none.
+func Acquire(pr Interface, paths []string, key uint64) (uint64, error) {
+	if len(paths) == 0 {
+		return 0, fmt.Errorf("no path to reserve")
+	}
+	for _, path := range paths {
+		if err := pr.Register(path, key); err != nil {
+			return 0, fmt.Errorf("failed to register key 0x%x through %s: %w", key, path, err)
+		}
+	}
+
+	reservation, err := pr.ReadReservation(paths[0])
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case reservation == nil:
+		if err := pr.Reserve(paths[0], key, TypeWriteExclusiveRegistrantsOnly); err != nil {
+			return 0, fmt.Errorf("failed to reserve %s with key 0x%x: %w", paths[0], key, err)
+		}
+		return 0, nil
+	case reservation.Key == key && reservation.Type == TypeWriteExclusiveRegistrantsOnly:
+		return 0, nil
+	case reservation.Key == key:
+		// the reservation taken by an earlier version of the driver only lets one path write
+		klog.V(2).Infof("scsipr: converting %s reservation of key 0x%x on %s", reservation.Type, key, paths[0])
+		if err := pr.Release(paths[0], key, reservation.Type); err != nil {
+			return 0, fmt.Errorf("failed to release reservation of %s: %w", paths[0], err)
+		}
+		if err := pr.Reserve(paths[0], key, TypeWriteExclusiveRegistrantsOnly); err != nil {
+			return 0, fmt.Errorf("failed to reserve %s with key 0x%x: %w", paths[0], key, err)
+		}
+		return 0, nil
+	default:
+		klog.Warningf("scsipr: preempting reservation of key 0x%x on %s", reservation.Key, paths[0])
+		if err := pr.Preempt(paths[0], key, reservation.Key, TypeWriteExclusiveRegistrantsOnly); err != nil {
+			return 0, fmt.Errorf("failed to preempt key 0x%x on %s: %w", reservation.Key, paths[0], err)
+		}
+		return reservation.Key, nil
+	}
+}
+
+// Release releases the reservation held with the key on a LUN, if any, and unregisters the key from
+// every path to the LUN
+func Release(pr Interface, paths []string, key uint64) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	reservation, err := pr.ReadReservation(paths[0])
+	if err != nil {
+		return err
+	}
+	if reservation != nil && reservation.Key == key {
+		if err := pr.Release(paths[0], key, reservation.Type); err != nil {
+			return fmt.Errorf("failed to release reservation of %s: %w", paths[0], err)
+		}
+	}
+	for _, path := range paths {
+		if err := pr.Unregister(path, key); err != nil {
+			return fmt.Errorf("failed to unregister key 0x%x through %s: %w", key, path, err)
+		}
+	}
+	return nil
+}
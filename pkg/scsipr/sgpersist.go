@@ -0,0 +1,138 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scsipr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/utils/exec"
+)
+
+// resConflictExitStatus is the exit status of sg_persist when the device reports a reservation
+// conflict
+const resConflictExitStatus = 24
+
+// types are the reservation types by their name in the output of sg_persist
+var types = map[string]Type{
+	"Write Exclusive":                    1,
+	"Exclusive Access":                   3,
+	"Write Exclusive, registrants only":  5,
+	"Exclusive Access, registrants only": 6,
+	"Write Exclusive, all registrants":   7,
+	"Exclusive Access, all registrants":  8,
+}
+
+// sgPersist issues the commands with sg_persist from sg3_utils
+type sgPersist struct {
+	exec exec.Interface
+}
+
+var _ Interface = &sgPersist{}
+
+// New returns an Interface that runs sg_persist
+func New(executor exec.Interface) Interface {
+	return &sgPersist{exec: executor}
+}
+
+func (s *sgPersist) ReadReservation(device string) (*Reservation, error) {
+	out, err := s.run(device, "--in", "--read-reservation")
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(out, "NO reservation") {
+		return nil, nil
+	}
+
+	reservation := &Reservation{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if key, ok := strings.CutPrefix(line, "Key="); ok {
+			if reservation.Key, err = parseKey(key); err != nil {
+				return nil, err
+			}
+		}
+		if _, name, ok := strings.Cut(line, "type: "); ok {
+			t, ok := types[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown reservation type %q of %s", name, device)
+			}
+			reservation.Type = t
+		}
+	}
+	if reservation.Key == 0 || reservation.Type == 0 {
+		return nil, fmt.Errorf("failed to parse reservation of %s: %s", device, out)
+	}
+	return reservation, nil
+}
+
+func (s *sgPersist) Register(device string, key uint64) error {
+	_, err := s.run(device, "--out", "--register-ignore", formatKey("--param-sark", key))
+	return err
+}
+
+// Unregister removes the key registered through the device, whichever it is, so that it succeeds if
+// the key was preempted meanwhile
+func (s *sgPersist) Unregister(device string, key uint64) error {
+	_, err := s.run(device, "--out", "--register-ignore", formatKey("--param-sark", 0))
+	return err
+}
+
+func (s *sgPersist) Reserve(device string, key uint64, t Type) error {
+	_, err := s.run(device, "--out", "--reserve", formatKey("--param-rk", key), formatType(t))
+	return err
+}
+
+func (s *sgPersist) Preempt(device string, key, preemptedKey uint64, t Type) error {
+	_, err := s.run(device, "--out", "--preempt-abort", formatKey("--param-rk", key), formatKey("--param-sark", preemptedKey), formatType(t))
+	return err
+}
+
+func (s *sgPersist) Release(device string, key uint64, t Type) error {
+	_, err := s.run(device, "--out", "--release", formatKey("--param-rk", key), formatType(t))
+	return err
+}
+
+// run runs sg_persist on a device without the INQUIRY header in its output
+func (s *sgPersist) run(device string, args ...string) (string, error) {
+	args = append(append([]string{"--no-inquiry"}, args...), device)
+	out, err := s.exec.Command("sg_persist", args...).CombinedOutput()
+	if err != nil {
+		if exitErr, ok := err.(exec.ExitError); ok && exitErr.ExitStatus() == resConflictExitStatus {
+			return "", fmt.Errorf("sg_persist %s: %w", strings.Join(args, " "), ErrConflict)
+		}
+		return "", fmt.Errorf("sg_persist %s failed: %v, output: %s", strings.Join(args, " "), err, out)
+	}
+	return string(out), nil
+}
+
+func formatKey(flag string, key uint64) string {
+	return fmt.Sprintf("%s=0x%x", flag, key)
+}
+
+func formatType(t Type) string {
+	return fmt.Sprintf("--prout-type=%d", int(t))
+}
+
+func parseKey(s string) (uint64, error) {
+	key, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid reservation key %q: %v", s, err)
+	}
+	return key, nil
+}